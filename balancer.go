@@ -0,0 +1,223 @@
+package p2p
+
+import (
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Balancer orders the peers matching a request, the first one is tried first
+// and the rest are used, in order, to fail over.
+type Balancer interface {
+	Order(key string, peers []*Peer) []*Peer
+	Begin(peer *Peer)
+	End(peer *Peer, latency time.Duration, err error)
+}
+
+type stateless struct{}
+
+func (stateless) Begin(peer *Peer) {}
+
+func (stateless) End(peer *Peer, latency time.Duration, err error) {}
+
+type RandomBalancer struct {
+	stateless
+}
+
+func (b *RandomBalancer) Order(key string, peers []*Peer) []*Peer {
+	ordered := append([]*Peer(nil), peers...)
+	rand.Shuffle(len(ordered), func(i, j int) {
+		ordered[i], ordered[j] = ordered[j], ordered[i]
+	})
+	return ordered
+}
+
+type RoundRobinBalancer struct {
+	stateless
+	next uint64
+}
+
+func (b *RoundRobinBalancer) Order(key string, peers []*Peer) []*Peer {
+	if len(peers) == 0 {
+		return nil
+	}
+
+	sorted := sortedById(peers)
+	offset := int((atomic.AddUint64(&b.next, 1) - 1) % uint64(len(sorted)))
+	return append(sorted[offset:], sorted[:offset]...)
+}
+
+type LeastOutstandingBalancer struct {
+	mutex       sync.Mutex
+	outstanding map[string]int
+}
+
+func (b *LeastOutstandingBalancer) Order(key string, peers []*Peer) []*Peer {
+	ordered := (&RandomBalancer{}).Order(key, peers)
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return b.outstanding[ordered[i].Id] < b.outstanding[ordered[j].Id]
+	})
+	return ordered
+}
+
+func (b *LeastOutstandingBalancer) Begin(peer *Peer) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.outstanding == nil {
+		b.outstanding = make(map[string]int)
+	}
+	b.outstanding[peer.Id]++
+}
+
+func (b *LeastOutstandingBalancer) End(peer *Peer, latency time.Duration, err error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.outstanding[peer.Id]--
+	if b.outstanding[peer.Id] <= 0 {
+		delete(b.outstanding, peer.Id)
+	}
+}
+
+// LatencyBalancer picks peers at random, weighted by the inverse of an
+// exponentially weighted moving average of their latency. Failed requests
+// count as a Penalty latency. Peers without samples are tried first.
+type LatencyBalancer struct {
+	Alpha   float64
+	Penalty time.Duration
+
+	mutex sync.Mutex
+	ewma  map[string]float64
+}
+
+func NewLatencyBalancer() *LatencyBalancer {
+	return &LatencyBalancer{Alpha: 0.3, Penalty: 5 * time.Second}
+}
+
+func (b *LatencyBalancer) Order(key string, peers []*Peer) []*Peer {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	var ordered, sampled []*Peer
+	var weights []float64
+	for _, peer := range peers {
+		latency, ok := b.ewma[peer.Id]
+		if !ok {
+			ordered = append(ordered, peer)
+			continue
+		}
+		sampled = append(sampled, peer)
+		weights = append(weights, 1/math.Max(latency, 1))
+	}
+
+	rand.Shuffle(len(ordered), func(i, j int) {
+		ordered[i], ordered[j] = ordered[j], ordered[i]
+	})
+
+	for len(sampled) > 0 {
+		var total float64
+		for _, w := range weights {
+			total += w
+		}
+
+		i, r := 0, rand.Float64()*total
+		for ; i < len(weights)-1; i++ {
+			r -= weights[i]
+			if r < 0 {
+				break
+			}
+		}
+
+		ordered = append(ordered, sampled[i])
+		sampled = append(sampled[:i], sampled[i+1:]...)
+		weights = append(weights[:i], weights[i+1:]...)
+	}
+
+	return ordered
+}
+
+func (b *LatencyBalancer) Begin(peer *Peer) {}
+
+func (b *LatencyBalancer) End(peer *Peer, latency time.Duration, err error) {
+	if err != nil && latency < b.Penalty {
+		latency = b.Penalty
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.ewma == nil {
+		b.ewma = make(map[string]float64)
+	}
+
+	current, ok := b.ewma[peer.Id]
+	if !ok {
+		b.ewma[peer.Id] = float64(latency)
+		return
+	}
+	b.ewma[peer.Id] = b.Alpha*float64(latency) + (1-b.Alpha)*current
+}
+
+// ConsistentHashBalancer maps each key to the same peer as long as the peer
+// set does not change, failing over to the next peers on the hash ring.
+type ConsistentHashBalancer struct {
+	stateless
+	Replicas int
+}
+
+func (b *ConsistentHashBalancer) Order(key string, peers []*Peer) []*Peer {
+	replicas := b.Replicas
+	if replicas <= 0 {
+		replicas = 64
+	}
+
+	type point struct {
+		hash uint64
+		peer *Peer
+	}
+
+	ring := make([]point, 0, len(peers)*replicas)
+	for _, peer := range peers {
+		for i := 0; i < replicas; i++ {
+			ring = append(ring, point{hash64(peer.Id + "#" + strconv.Itoa(i)), peer})
+		}
+	}
+
+	sort.Slice(ring, func(i, j int) bool { return ring[i].hash < ring[j].hash })
+
+	h := hash64(key)
+	start := sort.Search(len(ring), func(i int) bool { return ring[i].hash >= h })
+
+	seen := make(map[string]bool, len(peers))
+	ordered := make([]*Peer, 0, len(peers))
+	for i := 0; i < len(ring) && len(ordered) < len(peers); i++ {
+		pt := ring[(start+i)%len(ring)]
+		if !seen[pt.peer.Id] {
+			seen[pt.peer.Id] = true
+			ordered = append(ordered, pt.peer)
+		}
+	}
+	return ordered
+}
+
+func hash64(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return h.Sum64()
+}
+
+func sortedById(peers []*Peer) []*Peer {
+	sorted := append([]*Peer(nil), peers...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Id < sorted[j].Id })
+	return sorted
+}
@@ -0,0 +1,118 @@
+package p2p_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/yaien/p2p"
+)
+
+func testPeers(n int) []*p2p.Peer {
+	peers := make([]*p2p.Peer, n)
+	for i := range peers {
+		peers[i] = &p2p.Peer{Id: fmt.Sprintf("peer-%d", i)}
+	}
+	return peers
+}
+
+func TestRoundRobinBalancer(t *testing.T) {
+	b := &p2p.RoundRobinBalancer{}
+	peers := testPeers(3)
+
+	seen := make(map[string]int)
+	for i := 0; i < 6; i++ {
+		seen[b.Order("", peers)[0].Id]++
+	}
+
+	for _, peer := range peers {
+		if seen[peer.Id] != 2 {
+			t.Errorf("expected %s to be picked twice, got %d", peer.Id, seen[peer.Id])
+		}
+	}
+}
+
+func TestConsistentHashBalancer(t *testing.T) {
+	b := &p2p.ConsistentHashBalancer{}
+	peers := testPeers(5)
+
+	first := b.Order("user-42", peers)
+	if len(first) != len(peers) {
+		t.Fatalf("expected every peer as a candidate, got %d", len(first))
+	}
+
+	for i := 0; i < 10; i++ {
+		if b.Order("user-42", peers)[0].Id != first[0].Id {
+			t.Fatal("expected the same key to map to the same peer")
+		}
+	}
+
+	var rest []*p2p.Peer
+	for _, peer := range peers {
+		if peer.Id != first[0].Id {
+			rest = append(rest, peer)
+		}
+	}
+
+	if b.Order("user-42", rest)[0].Id != first[1].Id {
+		t.Error("expected the key to move to the next peer on the ring")
+	}
+}
+
+func TestLeastOutstandingBalancer(t *testing.T) {
+	b := &p2p.LeastOutstandingBalancer{}
+	peers := testPeers(2)
+
+	b.Begin(peers[0])
+	if b.Order("", peers)[0].Id != peers[1].Id {
+		t.Error("expected the idle peer first")
+	}
+
+	b.End(peers[0], time.Millisecond, nil)
+	b.Begin(peers[1])
+	if b.Order("", peers)[0].Id != peers[0].Id {
+		t.Error("expected the idle peer first")
+	}
+}
+
+func TestP2P_Http_Request_Failover(t *testing.T) {
+	transport := &p2p.HttpTransport{}
+	from := p2p.New(p2p.Options{Transport: transport, Balancer: &p2p.RoundRobinBalancer{}})
+
+	var calls int
+	for i := 0; i < 2; i++ {
+		mx := http.NewServeMux()
+		srv := httptest.NewServer(mx)
+		defer srv.Close()
+
+		to := p2p.New(p2p.Options{Addr: srv.URL, Name: "target-p2p", Transport: transport})
+		to.HandleFunc(func(ctx context.Context, r *p2p.MessageRequest) ([]byte, error) {
+			calls++
+			return []byte(`{ "message": "received" }`), nil
+		})
+		p2p.NewHttpServer(to, nil, "").Register(mx)
+
+		err := from.Discover(to.CurrentAddr())
+		if err != nil {
+			t.Fatalf("failed at discover: %s", err)
+		}
+
+		if i == 0 {
+			srv.Close()
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		_, err := from.Request("target-p2p", "message", []byte(`{}`))
+		if err != nil {
+			t.Fatalf("expected request to fail over, got %s", err)
+		}
+	}
+
+	if calls != 2 {
+		t.Errorf("expected the live peer to serve both requests, got %d", calls)
+	}
+}
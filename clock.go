@@ -0,0 +1,183 @@
+package p2p
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Clock is the time source of a node, it drives failure detection, eviction
+// and the latency of simulated networks.
+type Clock interface {
+	Now() time.Time
+	Sleep(ctx context.Context, d time.Duration) error
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker delivers the ticks of a Clock, dropping them for slow receivers as
+// time.Ticker does.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) Sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+func (systemClock) NewTicker(d time.Duration) Ticker {
+	return systemTicker{time.NewTicker(d)}
+}
+
+type systemTicker struct {
+	*time.Ticker
+}
+
+func (t systemTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
+
+// ManualClock only moves when told to, sleepers and tickers wait for Advance
+// or Set to move it past their deadline, so whoever drives the test decides
+// how much simulated time goes by.
+type ManualClock struct {
+	mutex    sync.Mutex
+	now      time.Time
+	tickers  map[*manualTicker]bool
+	sleepers map[*manualSleeper]bool
+	blocked  *sync.Cond
+}
+
+type manualSleeper struct {
+	until time.Time
+	done  chan struct{}
+}
+
+func NewManualClock(start time.Time) *ManualClock {
+	c := &ManualClock{now: start, tickers: make(map[*manualTicker]bool), sleepers: make(map[*manualSleeper]bool)}
+	c.blocked = sync.NewCond(&c.mutex)
+	return c
+}
+
+func (c *ManualClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+// Sleep blocks until the clock is moved d past now, or the context is done.
+func (c *ManualClock) Sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	c.mutex.Lock()
+	s := &manualSleeper{until: c.now.Add(d), done: make(chan struct{})}
+	c.sleepers[s] = true
+	c.blocked.Broadcast()
+	c.mutex.Unlock()
+
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		c.mutex.Lock()
+		delete(c.sleepers, s)
+		c.mutex.Unlock()
+		return ctx.Err()
+	}
+}
+
+// BlockUntil waits for n goroutines to be sleeping on the clock, so a test
+// can advance it once the code under test is waiting.
+func (c *ManualClock) BlockUntil(n int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for len(c.sleepers) < n {
+		c.blocked.Wait()
+	}
+}
+
+// NewTicker returns a ticker firing as Advance and Set move the clock past
+// each period.
+func (c *ManualClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for ManualClock.NewTicker")
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	t := &manualTicker{clock: c, period: d, next: c.now.Add(d), c: make(chan time.Time, 1)}
+	c.tickers[t] = true
+	return t
+}
+
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d)
+	c.tick()
+}
+
+func (c *ManualClock) Set(t time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = t
+	c.tick()
+}
+
+func (c *ManualClock) tick() {
+	for s := range c.sleepers {
+		if !c.now.Before(s.until) {
+			close(s.done)
+			delete(c.sleepers, s)
+		}
+	}
+
+	for t := range c.tickers {
+		if c.now.Before(t.next) {
+			continue
+		}
+
+		select {
+		case t.c <- c.now:
+		default:
+		}
+
+		for !c.now.Before(t.next) {
+			t.next = t.next.Add(t.period)
+		}
+	}
+}
+
+type manualTicker struct {
+	clock  *ManualClock
+	period time.Duration
+	next   time.Time
+	c      chan time.Time
+}
+
+func (t *manualTicker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *manualTicker) Stop() {
+	t.clock.mutex.Lock()
+	defer t.clock.mutex.Unlock()
+	delete(t.clock.tickers, t)
+}
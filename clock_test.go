@@ -0,0 +1,40 @@
+package p2p_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yaien/p2p"
+)
+
+func TestManualClock_Sleep(t *testing.T) {
+	clock := p2p.NewManualClock(time.Now())
+	start := clock.Now()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			clock.Sleep(context.Background(), 50*time.Millisecond)
+		}()
+	}
+
+	clock.BlockUntil(3)
+	clock.Advance(50 * time.Millisecond)
+	wg.Wait()
+
+	if elapsed := clock.Now().Sub(start); elapsed != 50*time.Millisecond {
+		t.Errorf("expected concurrent sleepers to share the same 50ms, the clock moved %s", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := clock.Sleep(ctx, time.Second)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected a done context to end the sleep, got %v", err)
+	}
+}
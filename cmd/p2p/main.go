@@ -2,12 +2,13 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"log"
-	"net"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -35,53 +36,179 @@ func init() {
 func main() {
 	cmd := root()
 	cmd.AddCommand(monitor())
+	cmd.AddCommand(keygen())
 	cmd.Execute()
 }
 
+// protocols advertised by each --transport, in order of preference.
+var protocols = map[string][]string{
+	"rest":      {p2p.ProtocolHttp},
+	"grpc":      {p2p.ProtocolGrpc},
+	"tcp":       {p2p.ProtocolTcp},
+	"websocket": {p2p.ProtocolWebSocket, p2p.ProtocolHttp},
+	"mux":       {p2p.ProtocolGrpc, p2p.ProtocolWebSocket, p2p.ProtocolHttp},
+}
+
+// preference puts the advertised protocols first, so peers speaking the same
+// ones are reached the same way, falling back to the others for the rest.
+func preference(advertised []string) []string {
+	order := append([]string{}, advertised...)
+	seen := make(map[string]bool)
+	for _, protocol := range order {
+		seen[protocol] = true
+	}
+
+	for _, protocol := range []string{p2p.ProtocolGrpc, p2p.ProtocolWebSocket, p2p.ProtocolHttp, p2p.ProtocolTcp} {
+		if !seen[protocol] {
+			order = append(order, protocol)
+		}
+	}
+	return order
+}
+
+func identityPath() string {
+	config, _ := os.UserConfigDir()
+	return filepath.Join(config, "p2p", "identity.pem")
+}
+
 func root() *cobra.Command {
 
 	cmd := &cobra.Command{
 		Use: "p2p",
 		RunE: func(cmd *cobra.Command, args []string) error {
 
-			l, err := net.Listen("tcp", fmt.Sprintf(":%d", viper.GetInt("port")))
+			listen := viper.GetString("listen")
+			if listen == "" {
+				listen = fmt.Sprintf(":%d", viper.GetInt("port"))
+			}
+
+			mode, err := strconv.ParseUint(viper.GetString("socket-mode"), 8, 32)
+			if err != nil {
+				return fmt.Errorf("invalid socket mode: %w", err)
+			}
+
+			l, err := p2p.Listen(listen, os.FileMode(mode))
 			if err != nil {
 				return fmt.Errorf("failed at listen: %w", err)
 			}
 
+			addr := viper.GetString("address")
+			if addr == "" && strings.HasPrefix(listen, "unix://") {
+				addr = listen
+			}
+
+			var swim *p2p.SwimOptions
+			if viper.GetBool("swim") {
+				swim = &p2p.SwimOptions{Interval: viper.GetDuration("swim-interval")}
+			}
+
+			var detector p2p.FailureDetector
+			if viper.GetString("detector") == "misses" {
+				detector = p2p.NewMissCountDetector(1, 3)
+			}
+
+			var balancer p2p.Balancer
+			switch viper.GetString("balancer") {
+			case "round-robin":
+				balancer = &p2p.RoundRobinBalancer{}
+			case "least-outstanding":
+				balancer = &p2p.LeastOutstandingBalancer{}
+			case "latency":
+				balancer = p2p.NewLatencyBalancer()
+			case "hash":
+				balancer = &p2p.ConsistentHashBalancer{}
+			}
+
+			identity, err := p2p.LoadOrCreateIdentity(viper.GetString("identity"))
+			if err != nil {
+				return fmt.Errorf("failed loading identity: %w", err)
+			}
+
+			var serverTLS, clientTLS *tls.Config
+			if viper.GetString("tls-cert") != "" {
+				cert, key, ca := viper.GetString("tls-cert"), viper.GetString("tls-key"), viper.GetString("tls-ca")
+				serverTLS, err = p2p.ServerTLSConfig(cert, key, ca)
+				if err != nil {
+					return fmt.Errorf("failed loading server tls: %w", err)
+				}
+				clientTLS, err = p2p.ClientTLSConfig(cert, key, ca)
+				if err != nil {
+					return fmt.Errorf("failed loading client tls: %w", err)
+				}
+			}
+
+			transport := viper.GetString("transport")
+			advertised, ok := protocols[transport]
+			if !ok {
+				return fmt.Errorf("unknown transport '%s'", transport)
+			}
+
+			var endpoints []*p2p.Endpoint
+			for _, raw := range viper.GetStringSlice("endpoints") {
+				e, err := p2p.ParseEndpoint(raw)
+				if err != nil {
+					return err
+				}
+				endpoints = append(endpoints, e)
+			}
+
+			key := viper.GetString("key")
+			multi := &p2p.MultiTransport{
+				Transports: map[string]p2p.Transport{
+					p2p.ProtocolHttp:      p2p.NewHttpTransport(key, p2p.HttpOptions{TLSConfig: clientTLS}),
+					p2p.ProtocolGrpc:      &p2p.GrpcTransport{Key: key, TLSConfig: clientTLS},
+					p2p.ProtocolWebSocket: &p2p.WebSocketTransport{Key: key, TLSConfig: clientTLS},
+					p2p.ProtocolTcp:       &p2p.TcpTransport{Key: key, TLSConfig: clientTLS},
+				},
+				Preference: preference(advertised),
+			}
+
 			p := p2p.New(p2p.Options{
-				Addr:      viper.GetString("address"),
+				Addr:      addr,
 				Name:      viper.GetString("name"),
+				Labels:    viper.GetStringMapString("labels"),
+				Protocols: advertised,
+				Endpoints: endpoints,
+				Identity:  identity,
 				Lookup:    viper.GetStringSlice("lookup"),
-				Transport: &p2p.HttpTransport{Key: viper.GetString("key")},
+				Transport: multi,
+				Swim:      swim,
+				Detector:  detector,
+				Balancer:  balancer,
+				Evict:     viper.GetDuration("evict"),
+				Timeout:   viper.GetDuration("timeout"),
 			})
 
-			if viper.Get("transport") == "rest" {
-				go func() {
-					log.Println("rest server listening on", p.CurrentAddr())
-					key := viper.GetString("key")
-					sub := p2p.NewSubscriber(p.Channel())
-					srv := p2p.NewHttpServer(p, sub, key)
-					err = srv.Serve(l)
-					if err != nil {
-						log.Fatalf("failed initializing server: %s", err)
-					}
-				}()
-			}
-
-			if viper.Get("transport") == "grpc" {
-				go func() {
-					p.SetTransport(&p2p.GrpcTransport{})
-					log.Println("grpc server listening on", p.CurrentAddr())
-					sub := p2p.NewSubscriber(p.Channel())
-					srv := p2p.NewGrpcServer(p, sub)
-					err = srv.Serve(l)
-					if err != nil {
-						log.Fatalf("failed initializing server: %s", err)
-					}
-				}()
+			sub := p2p.NewSubscriber(p.Channel())
+
+			var srv p2p.Server
+			switch transport {
+			case "rest":
+				rest := p2p.NewHttpServer(p, sub, key)
+				rest.TLSConfig = serverTLS
+				srv = rest
+			case "grpc":
+				grpc := p2p.NewGrpcServer(p, sub, key)
+				grpc.TLSConfig = serverTLS
+				srv = grpc
+			case "tcp":
+				tcp := p2p.NewTcpServer(p, sub, key)
+				tcp.TLSConfig = serverTLS
+				srv = tcp
+			default:
+				mux := p2p.NewMuxServer(p, sub, key)
+				mux.TLSConfig = serverTLS
+				srv = mux
 			}
 
+			go func() {
+				log.Println(transport, "server listening on", p.CurrentAddr())
+				err := srv.Serve(l)
+				if err != nil {
+					log.Fatalf("failed initializing server: %s", err)
+				}
+			}()
+
 			if viper.GetBool("ngrok") {
 				ctx, cancel := context.WithTimeout(cmd.Context(), 30*time.Second)
 				defer cancel()
@@ -89,48 +216,101 @@ func root() *cobra.Command {
 				if err != nil {
 					log.Fatal(err)
 				}
-				url := tnl.Url()
-				if viper.GetString("transport") == "grpc" {
-					url = strings.TrimPrefix(url, "https://")
-				}
-				p.SetCurrentAddr(url)
+				p.SetCurrentAddr(tnl.Url())
 				log.Println("ngrok tunnel listening on", tnl.Url())
 				log.Println("ngrok agent listening on", tnl.AgentUrl())
 				defer tnl.Close()
 			}
 
-			go p.Start()
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, os.Kill)
+			defer stop()
+
+			go p.Start(ctx)
 
-			ctx, _ := signal.NotifyContext(cmd.Context(), os.Interrupt, os.Kill)
 			<-ctx.Done()
 			log.Println("received stop request")
-			return nil
+			return p.Close()
 		},
 	}
 
 	flags := cmd.Flags()
 	flags.IntP("port", "p", 3000, "use -p to especify the current localhost server's port")
+	flags.String("listen", "", "use --listen to listen on a host:port or a unix:///path socket instead of --port")
+	flags.String("socket-mode", "0660", "use --socket-mode to set the file mode of a --listen unix socket")
 	flags.String("ngrok-authtoken", "", "use --ngrok-authtoken to set the ngrok auth token")
 	flags.Bool("ngrok", false, "use --ngrok to serve p2p on an ngrok tunnel")
 	flags.StringSliceP("lookup", "l", []string{}, "use --lookup to set initial addresses to be scanned")
 	flags.String("key", "", "use --key to set the p2p common's key")
+	flags.String("identity", identityPath(), "use --identity to set the path of the current client's key, created if missing")
 	flags.String("name", "", "use --name to set the current client's name")
-	flags.StringP("transport", "t", "rest", "use --transport [rest|grpc] to specify the current p2p transport")
+	flags.StringToString("labels", nil, "use --labels key=value,... to set the current client's labels")
+	flags.StringP("transport", "t", "rest", "use --transport [rest|grpc|websocket|tcp|mux] to specify the current p2p transport")
 	flags.StringP("address", "a", "", "use --address to specify the current p2p address")
+	flags.StringSlice("endpoints", nil, "use --endpoints scheme://host:port,... to advertise where the current client is reachable instead of deriving it from --address")
+	flags.String("tls-cert", "", "use --tls-cert to serve over tls with the given certificate, whatever the transport")
+	flags.String("tls-key", "", "use --tls-key to set the private key of --tls-cert")
+	flags.String("tls-ca", "", "use --tls-ca to trust the given ca and require client certificates signed by it")
+	flags.String("balancer", "random", "use --balancer [random|round-robin|least-outstanding|latency|hash] to choose how requests pick a peer")
+	flags.String("detector", "phi", "use --detector [phi|misses] to choose the peer failure detector")
+	flags.Duration("evict", 30*time.Second, "use --evict to set how long a dead peer is kept before being removed")
+	flags.Duration("timeout", 30*time.Second, "use --timeout to bound requests made without a deadline")
+	flags.Bool("swim", false, "use --swim to enable the swim gossip membership protocol")
+	flags.Duration("swim-interval", time.Second, "use --swim-interval to set the swim protocol period")
 	viper.BindPFlags(flags)
 
 	return cmd
 }
 
+func keygen() *cobra.Command {
+	var out string
+	var force bool
+	cmd := &cobra.Command{
+		Use:  "keygen",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, err := os.Stat(out)
+			if err == nil && !force {
+				return fmt.Errorf("%s already exists, use --force to overwrite it", out)
+			}
+
+			identity, err := p2p.GenerateIdentity()
+			if err != nil {
+				return err
+			}
+
+			err = identity.Save(out)
+			if err != nil {
+				return err
+			}
+
+			fmt.Println(identity.Id())
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&out, "out", "o", identityPath(), "use --out to set where the key is written")
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "use --force to overwrite an existing key")
+	return cmd
+}
+
 func monitor() *cobra.Command {
-	var transport string
+	var transport, key, cert, certKey, ca string
 	cmd := &cobra.Command{
 		Use:  "monitor [addr]",
 		Args: cobra.ExactArgs(1),
-		Run: func(cmd *cobra.Command, args []string) {
-			subscribe := p2p.Subscribe2Http
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var config *tls.Config
+			if cert != "" || ca != "" {
+				var err error
+				config, err = p2p.ClientTLSConfig(cert, certKey, ca)
+				if err != nil {
+					return fmt.Errorf("failed loading client tls: %w", err)
+				}
+			}
+
+			subscribe := p2p.NewHttpTransport(key, p2p.HttpOptions{TLSConfig: config}).Subscribe
 			if transport == "grpc" {
-				subscribe = p2p.Subscribe2Grpc
+				subscribe = (&p2p.GrpcTransport{Key: key, TLSConfig: config}).Subscribe
 			}
 			monitor := p2p.NewMonitor(args[0], subscribe)
 			monitor.SetContext(cmd.Context())
@@ -140,9 +320,14 @@ func monitor() *cobra.Command {
 			if err != nil {
 				log.Println(err)
 			}
+			return nil
 		},
 	}
 
 	cmd.Flags().StringVarP(&transport, "transport", "t", "rest", "--use n [rest|grpc] to specify the target monitor transport")
+	cmd.Flags().StringVar(&key, "key", "", "use --key to sign the monitor requests with the p2p common's key")
+	cmd.Flags().StringVar(&cert, "tls-cert", "", "use --tls-cert to present the given client certificate to tls servers")
+	cmd.Flags().StringVar(&certKey, "tls-key", "", "use --tls-key to set the private key of --tls-cert")
+	cmd.Flags().StringVar(&ca, "tls-ca", "", "use --tls-ca to trust the given ca when reaching tls servers")
 	return cmd
 }
@@ -0,0 +1,151 @@
+package p2p
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	ContentTypeJSON    = "application/json"
+	ContentTypeProto   = "application/protobuf"
+	ContentTypeMsgpack = "application/msgpack"
+)
+
+// Codec encodes the bodies of typed messages.
+type Codec interface {
+	ContentType() string
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+var (
+	JSONCodec    Codec = jsonCodec{}
+	ProtoCodec   Codec = protoCodec{}
+	MsgpackCodec Codec = msgpackCodec{}
+)
+
+var codecs sync.Map
+
+func init() {
+	RegisterCodec(JSONCodec)
+	RegisterCodec(ProtoCodec)
+	RegisterCodec(MsgpackCodec)
+}
+
+// RegisterCodec makes the codec available to typed handlers receiving
+// messages of its content type.
+func RegisterCodec(c Codec) {
+	codecs.Store(c.ContentType(), c)
+}
+
+// CodecFor returns the codec registered for the content type, messages
+// without one being json.
+func CodecFor(contentType string) (Codec, error) {
+	if contentType == "" {
+		return JSONCodec, nil
+	}
+
+	c, ok := codecs.Load(contentType)
+	if !ok {
+		return nil, Errorf(CodeInvalidArgument, "unsupported content type '%s'", contentType)
+	}
+	return c.(Codec), nil
+}
+
+type contentTypeKey struct{}
+
+// WithContentType sets the content type of the messages sent with ctx.
+func WithContentType(ctx context.Context, contentType string) context.Context {
+	return context.WithValue(ctx, contentTypeKey{}, contentType)
+}
+
+func contentType(ctx context.Context) string {
+	v, _ := ctx.Value(contentTypeKey{}).(string)
+	return v
+}
+
+// HandleTyped registers fn for the subject, decoding requests and encoding
+// replies with the codec of the request content type.
+func HandleTyped[Req, Resp any](mx *ServeMux, subject string, fn func(ctx context.Context, req *Req) (*Resp, error)) {
+	mx.HandleFunc(subject, func(ctx context.Context, r *MessageRequest) ([]byte, error) {
+		codec, err := CodecFor(r.ContentType)
+		if err != nil {
+			return nil, err
+		}
+
+		req := new(Req)
+		err = codec.Unmarshal(r.Body, req)
+		if err != nil {
+			return nil, Errorf(CodeInvalidArgument, "failed decoding %s request: %s", codec.ContentType(), err)
+		}
+
+		resp, err := fn(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := codec.Marshal(resp)
+		if err != nil {
+			return nil, fmt.Errorf("failed encoding %s reply: %w", codec.ContentType(), err)
+		}
+		return data, nil
+	})
+}
+
+// RequestTyped sends req encoded by codec to a peer matching pattern and
+// decodes its reply.
+func RequestTyped[Req, Resp any](ctx context.Context, p *P2P, codec Codec, pattern, subject string, req *Req) (*Resp, error) {
+	body, err := codec.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed encoding %s request: %w", codec.ContentType(), err)
+	}
+
+	data, err := p.RequestContext(WithContentType(ctx, codec.ContentType()), pattern, subject, body)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := new(Resp)
+	err = codec.Unmarshal(data, resp)
+	if err != nil {
+		return nil, fmt.Errorf("failed decoding %s reply: %w", codec.ContentType(), err)
+	}
+	return resp, nil
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string                { return ContentTypeJSON }
+func (jsonCodec) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+type protoCodec struct{}
+
+func (protoCodec) ContentType() string { return ContentTypeProto }
+
+func (protoCodec) Marshal(v any) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("%T is not a proto message", v)
+	}
+	return proto.Marshal(m)
+}
+
+func (protoCodec) Unmarshal(data []byte, v any) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("%T is not a proto message", v)
+	}
+	return proto.Unmarshal(data, m)
+}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) ContentType() string                { return ContentTypeMsgpack }
+func (msgpackCodec) Marshal(v any) ([]byte, error)      { return msgpack.Marshal(v) }
+func (msgpackCodec) Unmarshal(data []byte, v any) error { return msgpack.Unmarshal(data, v) }
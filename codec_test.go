@@ -0,0 +1,116 @@
+package p2p_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/yaien/p2p"
+	"github.com/yaien/p2p/p2ptest"
+)
+
+type greeting struct {
+	Name string `json:"name" msgpack:"name"`
+}
+
+type welcome struct {
+	Message string `json:"message" msgpack:"message"`
+}
+
+func typedNodes(t *testing.T, f p2ptest.Factory) (*p2p.P2P, *p2p.ServeMux) {
+	server := p2p.New(p2p.Options{Name: "server-p2p", Transport: f.NewTransport("")})
+	mx := p2p.NewServeMux()
+	server.Handle(mx)
+	addr := f.Serve(t, server, "")
+
+	client := p2p.New(p2p.Options{Name: "client-p2p", Transport: f.NewTransport("")})
+	err := client.Discover(addr)
+	if err != nil {
+		t.Fatalf("failed at discover: %s", err)
+	}
+	return client, mx
+}
+
+func TestRequestTyped(t *testing.T) {
+	for name, test := range map[string]struct {
+		factory p2ptest.Factory
+		codec   p2p.Codec
+	}{
+		"Http/JSON":         {httpFactory(), p2p.JSONCodec},
+		"Http/Msgpack":      {httpFactory(), p2p.MsgpackCodec},
+		"Grpc/Msgpack":      {grpcFactory(), p2p.MsgpackCodec},
+		"Tcp/Msgpack":       {tcpFactory(), p2p.MsgpackCodec},
+		"WebSocket/Msgpack": {webSocketFactory(), p2p.MsgpackCodec},
+		"Memory/JSON":       {memoryFactory(), p2p.JSONCodec},
+	} {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			client, mx := typedNodes(t, test.factory)
+			p2p.HandleTyped(mx, "greet", func(ctx context.Context, req *greeting) (*welcome, error) {
+				return &welcome{Message: "hello " + req.Name}, nil
+			})
+
+			res, err := p2p.RequestTyped[greeting, welcome](context.Background(), client, test.codec, "server-p2p", "greet", &greeting{Name: "ana"})
+			if err != nil {
+				t.Fatalf("failed at typed request: %s", err)
+			}
+
+			if res.Message != "hello ana" {
+				t.Errorf("unexpected reply: %+v", res)
+			}
+		})
+	}
+}
+
+func TestRequestTyped_Proto(t *testing.T) {
+	for name, factory := range map[string]p2ptest.Factory{
+		"Grpc": grpcFactory(),
+		"Http": httpFactory(),
+	} {
+		factory := factory
+		t.Run(name, func(t *testing.T) { testRequestTypedProto(t, factory) })
+	}
+}
+
+func testRequestTypedProto(t *testing.T, f p2ptest.Factory) {
+	client, mx := typedNodes(t, f)
+
+	var received string
+	mx.HandleFunc("raw", func(ctx context.Context, r *p2p.MessageRequest) ([]byte, error) {
+		received = r.ContentType
+		return nil, nil
+	})
+	p2p.HandleTyped(mx, "resolve", func(ctx context.Context, req *p2p.Endpoint) (*p2p.Peer, error) {
+		return &p2p.Peer{Addr: req.Address()}, nil
+	})
+
+	res, err := p2p.RequestTyped[p2p.Endpoint, p2p.Peer](context.Background(), client, p2p.ProtoCodec, "server-p2p", "resolve", &p2p.Endpoint{Scheme: "grpc", Host: "10.0.0.1", Port: 3000})
+	if err != nil {
+		t.Fatalf("failed at typed request: %s", err)
+	}
+
+	if res.Addr != "grpc://10.0.0.1:3000" {
+		t.Errorf("unexpected reply: %v", res)
+	}
+
+	_, err = client.RequestContext(p2p.WithContentType(context.Background(), p2p.ContentTypeProto), "server-p2p", "raw", nil)
+	if err != nil {
+		t.Fatalf("failed at request: %s", err)
+	}
+
+	if received != p2p.ContentTypeProto {
+		t.Errorf("expected the content type to reach the handler, got %q", received)
+	}
+}
+
+func TestHandleTyped_UnsupportedContentType(t *testing.T) {
+	mx := p2p.NewServeMux()
+	p2p.HandleTyped(mx, "greet", func(ctx context.Context, req *greeting) (*welcome, error) {
+		return &welcome{}, nil
+	})
+
+	_, err := mx.ServeP2P(context.Background(), &p2p.MessageRequest{Subject: "greet", ContentType: "text/yaml"})
+	if err == nil || !strings.Contains(err.Error(), "unsupported content type") {
+		t.Errorf("expected an unsupported content type error, got %v", err)
+	}
+}
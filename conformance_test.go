@@ -0,0 +1,112 @@
+package p2p_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/yaien/p2p"
+	"github.com/yaien/p2p/p2ptest"
+	"golang.org/x/net/nettest"
+)
+
+func httpFactory() p2ptest.Factory {
+	return p2ptest.Factory{
+		NewTransport: func(key string) p2p.Transport {
+			return &p2p.HttpTransport{Key: key}
+		},
+		Serve: func(tb testing.TB, p *p2p.P2P, key string) string {
+			mx := http.NewServeMux()
+			srv := httptest.NewServer(mx)
+			tb.Cleanup(srv.Close)
+			p2p.NewHttpServer(p, nil, key).Register(mx)
+			p.SetCurrentAddr(srv.URL)
+			return srv.URL
+		},
+	}
+}
+
+func grpcFactory() p2ptest.Factory {
+	return p2ptest.Factory{
+		NewTransport: func(key string) p2p.Transport {
+			return &p2p.GrpcTransport{Key: key}
+		},
+		Serve: func(tb testing.TB, p *p2p.P2P, key string) string {
+			return serveListener(tb, p, p2p.NewGrpcServer(p, nil, key))
+		},
+	}
+}
+
+func webSocketFactory() p2ptest.Factory {
+	return p2ptest.Factory{
+		NewTransport: func(key string) p2p.Transport {
+			return &p2p.WebSocketTransport{Key: key}
+		},
+		Serve: func(tb testing.TB, p *p2p.P2P, key string) string {
+			mx := http.NewServeMux()
+			srv := httptest.NewServer(mx)
+			tb.Cleanup(srv.Close)
+			p2p.NewWebSocketServer(p, nil, key).Register(mx)
+			p.SetCurrentAddr(srv.URL)
+			return srv.URL
+		},
+	}
+}
+
+func tcpFactory() p2ptest.Factory {
+	return p2ptest.Factory{
+		NewTransport: func(key string) p2p.Transport {
+			return &p2p.TcpTransport{Key: key, PoolSize: 2}
+		},
+		Serve: func(tb testing.TB, p *p2p.P2P, key string) string {
+			return serveListener(tb, p, p2p.NewTcpServer(p, nil, key))
+		},
+	}
+}
+
+func memoryFactory() p2ptest.Factory {
+	network := p2p.NewNetwork(p2p.NetworkOptions{})
+	return p2ptest.Factory{
+		NewTransport: func(key string) p2p.Transport {
+			return &p2p.MemoryTransport{Network: network, Key: key}
+		},
+		Serve: func(tb testing.TB, p *p2p.P2P, key string) string {
+			addr := "mem://" + tb.Name()
+			p.SetCurrentAddr(addr)
+			network.Attach(p, key)
+			tb.Cleanup(func() { network.Detach(addr) })
+			return addr
+		},
+	}
+}
+
+func serveListener(tb testing.TB, p *p2p.P2P, srv p2p.Server) string {
+	lis, err := nettest.NewLocalListener("tcp")
+	if err != nil {
+		tb.Fatalf("failed creating testing listener: %s", err)
+	}
+	go srv.Serve(lis)
+	tb.Cleanup(func() { srv.Close() })
+	p.SetCurrentAddr(lis.Addr().String())
+	return lis.Addr().String()
+}
+
+func TestHttpTransport_Conformance(t *testing.T) {
+	p2ptest.TestTransport(t, httpFactory())
+}
+
+func TestGrpcTransport_Conformance(t *testing.T) {
+	p2ptest.TestTransport(t, grpcFactory())
+}
+
+func TestMemoryTransport_Conformance(t *testing.T) {
+	p2ptest.TestTransport(t, memoryFactory())
+}
+
+func TestWebSocketTransport_Conformance(t *testing.T) {
+	p2ptest.TestTransport(t, webSocketFactory())
+}
+
+func TestTcpTransport_Conformance(t *testing.T) {
+	p2ptest.TestTransport(t, tcpFactory())
+}
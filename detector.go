@@ -0,0 +1,168 @@
+package p2p
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// FailureDetector decides whether a peer is alive, suspect or dead from the
+// outcome of the probes made to it.
+type FailureDetector interface {
+	Heartbeat(id string, at time.Time)
+	Miss(id string, at time.Time)
+	Status(id string, at time.Time) PeerStatus
+	Forget(id string)
+}
+
+// PhiAccrualDetector implements the phi accrual failure detector: it keeps a
+// window of heartbeat intervals per peer and reports how unlikely the current
+// silence is, as phi = -log10(1 - F(elapsed)).
+type PhiAccrualDetector struct {
+	SuspectThreshold float64
+	DeadThreshold    float64
+	WindowSize       int
+	MinStdDeviation  time.Duration
+	AcceptablePause  time.Duration
+	FirstHeartbeat   time.Duration
+
+	mutex   sync.Mutex
+	history map[string]*heartbeatHistory
+}
+
+type heartbeatHistory struct {
+	last      time.Time
+	intervals []float64
+}
+
+func NewPhiAccrualDetector() *PhiAccrualDetector {
+	return &PhiAccrualDetector{
+		SuspectThreshold: 3,
+		DeadThreshold:    8,
+		WindowSize:       100,
+		MinStdDeviation:  500 * time.Millisecond,
+		AcceptablePause:  5 * time.Second,
+		FirstHeartbeat:   5 * time.Second,
+	}
+}
+
+func (d *PhiAccrualDetector) Heartbeat(id string, at time.Time) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.history == nil {
+		d.history = make(map[string]*heartbeatHistory)
+	}
+
+	h, ok := d.history[id]
+	if !ok {
+		first := float64(d.FirstHeartbeat)
+		d.history[id] = &heartbeatHistory{last: at, intervals: []float64{first - first/4, first + first/4}}
+		return
+	}
+
+	h.intervals = append(h.intervals, float64(at.Sub(h.last)))
+	if d.WindowSize > 0 && len(h.intervals) > d.WindowSize {
+		h.intervals = h.intervals[len(h.intervals)-d.WindowSize:]
+	}
+	h.last = at
+}
+
+func (d *PhiAccrualDetector) Miss(id string, at time.Time) {}
+
+func (d *PhiAccrualDetector) Phi(id string, at time.Time) float64 {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	h, ok := d.history[id]
+	if !ok {
+		return 0
+	}
+
+	var mean, variance float64
+	for _, v := range h.intervals {
+		mean += v
+	}
+	mean /= float64(len(h.intervals))
+	for _, v := range h.intervals {
+		variance += (v - mean) * (v - mean)
+	}
+	variance /= float64(len(h.intervals))
+
+	std := math.Max(math.Sqrt(variance), float64(d.MinStdDeviation))
+	elapsed := float64(at.Sub(h.last))
+	y := (elapsed - mean - float64(d.AcceptablePause)) / std
+	e := math.Exp(-y * (1.5976 + 0.070566*y*y))
+	if elapsed > mean+float64(d.AcceptablePause) {
+		return -math.Log10(e / (1 + e))
+	}
+	return -math.Log10(1 - 1/(1+e))
+}
+
+func (d *PhiAccrualDetector) Status(id string, at time.Time) PeerStatus {
+	phi := d.Phi(id, at)
+	switch {
+	case phi >= d.DeadThreshold:
+		return PeerStatus_DEAD
+	case phi >= d.SuspectThreshold:
+		return PeerStatus_SUSPECT
+	default:
+		return PeerStatus_ALIVE
+	}
+}
+
+func (d *PhiAccrualDetector) Forget(id string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	delete(d.history, id)
+}
+
+// MissCountDetector marks a peer suspect or dead after a number of
+// consecutive failed probes.
+type MissCountDetector struct {
+	Suspect int
+	Dead    int
+
+	mutex  sync.Mutex
+	misses map[string]int
+}
+
+func NewMissCountDetector(suspect, dead int) *MissCountDetector {
+	return &MissCountDetector{Suspect: suspect, Dead: dead}
+}
+
+func (d *MissCountDetector) Heartbeat(id string, at time.Time) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	delete(d.misses, id)
+}
+
+func (d *MissCountDetector) Miss(id string, at time.Time) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.misses == nil {
+		d.misses = make(map[string]int)
+	}
+	d.misses[id]++
+}
+
+func (d *MissCountDetector) Status(id string, at time.Time) PeerStatus {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	misses := d.misses[id]
+	switch {
+	case misses >= d.Dead:
+		return PeerStatus_DEAD
+	case misses >= d.Suspect:
+		return PeerStatus_SUSPECT
+	default:
+		return PeerStatus_ALIVE
+	}
+}
+
+func (d *MissCountDetector) Forget(id string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	delete(d.misses, id)
+}
@@ -0,0 +1,80 @@
+package p2p_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/yaien/p2p"
+)
+
+func TestPhiAccrualDetector(t *testing.T) {
+	d := p2p.NewPhiAccrualDetector()
+	d.AcceptablePause = 0
+
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		d.Heartbeat("peer", start.Add(time.Duration(i)*time.Second))
+	}
+
+	last := start.Add(9 * time.Second)
+
+	if status := d.Status("peer", last.Add(time.Second)); status != p2p.PeerStatus_ALIVE {
+		t.Errorf("expected alive on schedule, got %s", status)
+	}
+
+	if status := d.Status("peer", last.Add(30*time.Second)); status != p2p.PeerStatus_DEAD {
+		t.Errorf("expected dead after a long silence, got %s", status)
+	}
+
+	d.Forget("peer")
+	if status := d.Status("peer", last.Add(30*time.Second)); status != p2p.PeerStatus_ALIVE {
+		t.Errorf("expected forgotten peer to be alive, got %s", status)
+	}
+}
+
+func TestP2P_Detector_Evict(t *testing.T) {
+	transport := &p2p.HttpTransport{}
+	from := p2p.New(p2p.Options{
+		Transport: transport,
+		Detector:  p2p.NewMissCountDetector(1, 2),
+		Evict:     200 * time.Millisecond,
+		Interval:  50 * time.Millisecond,
+	})
+
+	mx := http.NewServeMux()
+	srv := httptest.NewServer(mx)
+
+	to := p2p.New(p2p.Options{Addr: srv.URL, Name: "target-p2p", Transport: transport})
+	p2p.NewHttpServer(to, nil, "").Register(mx)
+
+	err := from.Discover(to.CurrentAddr())
+	if err != nil {
+		t.Fatalf("failed at discover: %s", err)
+	}
+
+	srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go from.Start(ctx)
+
+	statuses := make(map[p2p.PeerStatus]bool)
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) && len(from.Peers()) > 0 {
+		for _, peer := range from.Peers() {
+			statuses[peer.Status] = true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if len(from.Peers()) != 0 {
+		t.Fatalf("expected unreachable peer to be evicted")
+	}
+
+	if !statuses[p2p.PeerStatus_DEAD] {
+		t.Error("expected peer to be reported dead before eviction")
+	}
+}
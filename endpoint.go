@@ -0,0 +1,157 @@
+package p2p
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+var schemeProtocols = map[string]string{
+	"http":  ProtocolHttp,
+	"https": ProtocolHttp,
+	"ws":    ProtocolWebSocket,
+	"wss":   ProtocolWebSocket,
+	"grpc":  ProtocolGrpc,
+	"grpcs": ProtocolGrpc,
+	"tcp":   ProtocolTcp,
+}
+
+var protocolSchemes = map[string]string{
+	ProtocolHttp:      "http",
+	ProtocolWebSocket: "ws",
+	ProtocolGrpc:      "grpc",
+	ProtocolTcp:       "tcp",
+}
+
+var defaultPorts = map[string]string{"grpc": "80", "grpcs": "443"}
+
+// ParseEndpoint parses an endpoint like grpc://10.0.0.1:3000, https://node.com
+// or http+unix:///run/p2p.sock.
+func ParseEndpoint(s string) (*Endpoint, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("failed parsing endpoint: %w", err)
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	base := strings.TrimSuffix(scheme, "+unix")
+	_, ok := schemeProtocols[base]
+	if !ok {
+		return nil, fmt.Errorf("unsupported endpoint scheme '%s'", u.Scheme)
+	}
+
+	if base != scheme {
+		if u.Path == "" {
+			return nil, fmt.Errorf("missing socket path in endpoint '%s'", s)
+		}
+		return &Endpoint{Scheme: scheme, Path: u.Path}, nil
+	}
+
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("missing host in endpoint '%s'", s)
+	}
+
+	e := &Endpoint{Scheme: scheme, Host: u.Hostname(), Path: strings.TrimSuffix(u.Path, "/")}
+	if u.Port() != "" {
+		port, err := strconv.ParseUint(u.Port(), 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid endpoint port '%s'", u.Port())
+		}
+		e.Port = int32(port)
+	}
+
+	return e, nil
+}
+
+// Address formats the endpoint back to the form ParseEndpoint reads.
+func (e *Endpoint) Address() string {
+	if e.isUnix() {
+		return e.Scheme + "://" + e.Path
+	}
+
+	host := e.Host
+	if e.Port != 0 {
+		host = net.JoinHostPort(e.Host, strconv.Itoa(int(e.Port)))
+	}
+	return e.Scheme + "://" + host + e.Path
+}
+
+func (e *Endpoint) isUnix() bool {
+	return strings.HasSuffix(e.Scheme, "+unix")
+}
+
+// Protocol returns the protocol spoken at the endpoint.
+func (e *Endpoint) Protocol() string {
+	return schemeProtocols[strings.TrimSuffix(e.Scheme, "+unix")]
+}
+
+// dialAddr returns the addr the transport of the endpoint protocol dials, an
+// url for http and websocket, host:port for grpc and tcp.
+func (e *Endpoint) dialAddr() string {
+	if e.isUnix() {
+		return unixScheme + e.Path
+	}
+
+	host := e.Host
+	if e.Port != 0 {
+		host = net.JoinHostPort(e.Host, strconv.Itoa(int(e.Port)))
+	}
+
+	switch e.Scheme {
+	case "grpc", "grpcs":
+		if e.Port == 0 {
+			return net.JoinHostPort(e.Host, defaultPorts[e.Scheme])
+		}
+		return host
+	case "http", "ws":
+		return "http://" + host + e.Path
+	case "https", "wss":
+		return "https://" + host + e.Path
+	default:
+		return host
+	}
+}
+
+// endpointsOf derives the endpoints of a node serving the protocols at addr.
+func endpointsOf(addr string, protocols []string) []*Endpoint {
+	if addr == "" {
+		return nil
+	}
+
+	var endpoints []*Endpoint
+	for _, protocol := range protocols {
+		scheme, ok := protocolSchemes[protocol]
+		if !ok {
+			continue
+		}
+
+		var raw string
+		switch {
+		case strings.HasPrefix(addr, unixScheme):
+			raw = scheme + "+" + addr
+		case strings.HasPrefix(addr, "https://"):
+			raw = secure(scheme) + strings.TrimPrefix(addr, "https")
+		case strings.HasPrefix(addr, "http://"):
+			raw = scheme + strings.TrimPrefix(addr, "http")
+		default:
+			raw = scheme + "://" + addr
+		}
+
+		e, err := ParseEndpoint(raw)
+		if err == nil {
+			endpoints = append(endpoints, e)
+		}
+	}
+	return endpoints
+}
+
+func secure(scheme string) string {
+	switch scheme {
+	case "http", "ws", "grpc":
+		return scheme + "s"
+	default:
+		return scheme
+	}
+}
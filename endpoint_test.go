@@ -0,0 +1,165 @@
+package p2p_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/yaien/p2p"
+	"golang.org/x/net/nettest"
+)
+
+func TestParseEndpoint(t *testing.T) {
+	for raw, protocol := range map[string]string{
+		"http://10.0.0.1:3000":      p2p.ProtocolHttp,
+		"https://node.com/p2p":      p2p.ProtocolHttp,
+		"wss://node.com":            p2p.ProtocolWebSocket,
+		"grpc://[::1]:3000":         p2p.ProtocolGrpc,
+		"grpcs://node.com:443":      p2p.ProtocolGrpc,
+		"tcp://10.0.0.1:4000":       p2p.ProtocolTcp,
+		"http+unix:///run/p2p.sock": p2p.ProtocolHttp,
+	} {
+		e, err := p2p.ParseEndpoint(raw)
+		if err != nil {
+			t.Errorf("failed parsing %s: %s", raw, err)
+			continue
+		}
+
+		if e.Protocol() != protocol {
+			t.Errorf("expected %s to speak %s, got %s", raw, protocol, e.Protocol())
+		}
+
+		if e.Address() != raw {
+			t.Errorf("expected %s to round trip, got %s", raw, e.Address())
+		}
+	}
+
+	for _, raw := range []string{"ftp://node.com", "grpc://", "tcp+unix://", "http://node.com:99999", "10.0.0.1:3000"} {
+		_, err := p2p.ParseEndpoint(raw)
+		if err == nil {
+			t.Errorf("expected %s to be rejected", raw)
+		}
+	}
+}
+
+func TestMultiTransport_EndpointFallback(t *testing.T) {
+	dead, err := nettest.NewLocalListener("tcp")
+	if err != nil {
+		t.Fatalf("failed creating testing listener: %s", err)
+	}
+	dead.Close()
+
+	mx := http.NewServeMux()
+	srv := httptest.NewServer(mx)
+	defer srv.Close()
+
+	var endpoints []*p2p.Endpoint
+	for _, raw := range []string{"grpc://" + dead.Addr().String(), srv.URL} {
+		e, err := p2p.ParseEndpoint(raw)
+		if err != nil {
+			t.Fatalf("failed parsing %s: %s", raw, err)
+		}
+		endpoints = append(endpoints, e)
+	}
+
+	target := p2p.New(p2p.Options{Addr: srv.URL, Name: "target-p2p", Endpoints: endpoints, Transport: &p2p.HttpTransport{}})
+	target.HandleFunc(func(ctx context.Context, r *p2p.MessageRequest) ([]byte, error) {
+		return r.Body, nil
+	})
+	p2p.NewHttpServer(target, nil, "").Register(mx)
+
+	client := p2p.New(p2p.Options{Name: "client-p2p", Transport: multiTransport("")})
+	err = client.Discover(srv.URL)
+	if err != nil {
+		t.Fatalf("failed at discover: %s", err)
+	}
+
+	// a fresh transport knows no route, scanning has to fall back from the
+	// unreachable grpc endpoint and remember the http one for requests.
+	client.SetTransport(multiTransport(""))
+	client.Scan(context.Background())
+
+	peers := client.Peers()
+	if len(peers) != 1 || peers[0].Status != p2p.PeerStatus_ALIVE {
+		t.Fatalf("expected target to be alive, got %v", peers)
+	}
+
+	reply, err := client.Request("target-p2p", "echo", []byte(`{}`))
+	if err != nil {
+		t.Fatalf("failed at request: %s", err)
+	}
+
+	if string(reply) != `{}` {
+		t.Errorf("unexpected reply: %s", reply)
+	}
+}
+
+func TestMultiTransport_SendFallback(t *testing.T) {
+	dead, err := nettest.NewLocalListener("tcp")
+	if err != nil {
+		t.Fatalf("failed creating testing listener: %s", err)
+	}
+	dead.Close()
+
+	mx := http.NewServeMux()
+	srv := httptest.NewServer(mx)
+	defer srv.Close()
+
+	var endpoints []*p2p.Endpoint
+	for _, raw := range []string{"grpc://" + dead.Addr().String(), srv.URL} {
+		e, err := p2p.ParseEndpoint(raw)
+		if err != nil {
+			t.Fatalf("failed parsing %s: %s", raw, err)
+		}
+		endpoints = append(endpoints, e)
+	}
+
+	target := p2p.New(p2p.Options{Addr: srv.URL, Name: "target-p2p", Endpoints: endpoints, Transport: &p2p.HttpTransport{}})
+	target.HandleFunc(func(ctx context.Context, r *p2p.MessageRequest) ([]byte, error) {
+		return r.Body, nil
+	})
+	p2p.NewHttpServer(target, nil, "").Register(mx)
+
+	client := p2p.New(p2p.Options{Name: "client-p2p", Transport: multiTransport("")})
+	err = client.Discover(srv.URL)
+	if err != nil {
+		t.Fatalf("failed at discover: %s", err)
+	}
+
+	// without a scan a fresh transport knows no route, requests and leaves
+	// have to fall back from the unreachable grpc endpoint themselves.
+	client.SetTransport(multiTransport(""))
+	reply, err := client.Request("target-p2p", "echo", []byte(`{}`))
+	if err != nil {
+		t.Fatalf("failed at request: %s", err)
+	}
+
+	if string(reply) != `{}` {
+		t.Errorf("unexpected reply: %s", reply)
+	}
+
+	client.SetTransport(multiTransport(""))
+	client.Close()
+	if len(target.Peers()) != 0 {
+		t.Errorf("expected the client leave to reach the target, got %v", target.Peers())
+	}
+}
+
+func TestMultiTransport_GrpcsWithoutTLS(t *testing.T) {
+	transport := multiTransport("")
+	from := p2p.New(p2p.Options{Name: "from-p2p"}).State().Current
+
+	_, err := transport.Connect(context.Background(), from, "grpcs://127.0.0.1:443")
+	if !errors.Is(err, p2p.ErrNoTLS) {
+		t.Errorf("expected connecting to a grpcs endpoint without tls to fail, got %v", err)
+	}
+
+	e, _ := p2p.ParseEndpoint("grpcs://127.0.0.1:443")
+	to := &p2p.Peer{Id: "to", Addr: "https://127.0.0.1:443", Endpoints: []*p2p.Endpoint{e}}
+	_, err = transport.Send(context.Background(), from, to, "message", []byte(`{}`))
+	if !errors.Is(err, p2p.ErrNoProtocol) {
+		t.Errorf("expected the grpcs endpoint to be skipped, got %v", err)
+	}
+}
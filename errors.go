@@ -0,0 +1,132 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"google.golang.org/grpc/codes"
+)
+
+// Code classifies the errors replied by handlers.
+type Code string
+
+const (
+	CodeUnknown          Code = "unknown"
+	CodeInvalidArgument  Code = "invalid_argument"
+	CodeNotFound         Code = "not_found"
+	CodeNoHandler        Code = "no_handler"
+	CodeUnauthenticated  Code = "unauthenticated"
+	CodePermissionDenied Code = "permission_denied"
+	CodeRateLimited      Code = "rate_limited"
+	CodeDeadlineExceeded Code = "deadline_exceeded"
+	CodeUnavailable      Code = "unavailable"
+	CodeInternal         Code = "internal"
+)
+
+// Error is an error replied by a handler, rebuilt as is on the caller side
+// whatever the transport.
+type Error struct {
+	Code    Code
+	Message string
+	Details map[string]string
+}
+
+var ErrNoHandler = &Error{Code: CodeNoHandler, Message: "no handler"}
+
+// Errorf returns an error with the code and formatted message.
+func Errorf(code Code, format string, args ...any) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Is reports errors of the same code as target, so any missing handler error
+// matches ErrNoHandler.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// temporary reports errors another peer may not reply with.
+func (e *Error) temporary() bool {
+	return e.Code == CodeUnavailable || e.Code == CodeRateLimited
+}
+
+// toError converts what a handler failed with into the error replied to the
+// caller, keeping the whole message of wrapped errors.
+func toError(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		if e.Message == err.Error() {
+			return e
+		}
+		return &Error{Code: e.Code, Message: err.Error(), Details: e.Details}
+	}
+
+	code := CodeUnknown
+	switch {
+	case errors.Is(err, ErrRateLimited):
+		code = CodeRateLimited
+	case errors.Is(err, context.DeadlineExceeded):
+		code = CodeDeadlineExceeded
+	case errors.Is(err, ErrInvalidProof):
+		code = CodeUnauthenticated
+	}
+	return &Error{Code: code, Message: err.Error()}
+}
+
+func (e *Error) status() *ErrorStatus {
+	return &ErrorStatus{Code: string(e.Code), Message: e.Message, Details: e.Details}
+}
+
+func (s *ErrorStatus) err() *Error {
+	return &Error{Code: Code(s.Code), Message: s.Message, Details: s.Details}
+}
+
+func (e *Error) httpStatus() int {
+	switch e.Code {
+	case CodeInvalidArgument:
+		return http.StatusBadRequest
+	case CodeNotFound, CodeNoHandler:
+		return http.StatusNotFound
+	case CodeUnauthenticated:
+		return http.StatusUnauthorized
+	case CodePermissionDenied:
+		return http.StatusForbidden
+	case CodeRateLimited:
+		return http.StatusTooManyRequests
+	case CodeDeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case CodeUnavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func (e *Error) grpcCode() codes.Code {
+	switch e.Code {
+	case CodeInvalidArgument:
+		return codes.InvalidArgument
+	case CodeNotFound, CodeNoHandler:
+		return codes.NotFound
+	case CodeUnauthenticated:
+		return codes.Unauthenticated
+	case CodePermissionDenied:
+		return codes.PermissionDenied
+	case CodeRateLimited:
+		return codes.ResourceExhausted
+	case CodeDeadlineExceeded:
+		return codes.DeadlineExceeded
+	case CodeUnavailable:
+		return codes.Unavailable
+	case CodeInternal:
+		return codes.Internal
+	default:
+		return codes.Unknown
+	}
+}
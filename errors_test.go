@@ -0,0 +1,87 @@
+package p2p_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/yaien/p2p"
+	"github.com/yaien/p2p/p2ptest"
+)
+
+func TestError_RoundTrip(t *testing.T) {
+	for name, f := range map[string]p2ptest.Factory{
+		"Http":      httpFactory(),
+		"Grpc":      grpcFactory(),
+		"WebSocket": webSocketFactory(),
+		"Tcp":       tcpFactory(),
+		"Memory":    memoryFactory(),
+	} {
+		f := f
+		t.Run(name, func(t *testing.T) {
+			client, mx := typedNodes(t, f)
+			mx.HandleFunc("orders.get", func(ctx context.Context, r *p2p.MessageRequest) ([]byte, error) {
+				return nil, &p2p.Error{Code: p2p.CodeNotFound, Message: "order 42 not found", Details: map[string]string{"id": "42"}}
+			})
+			mx.HandleFunc("orders.fail", func(ctx context.Context, r *p2p.MessageRequest) ([]byte, error) {
+				return nil, errors.New("database down")
+			})
+
+			_, err := client.Request("server-p2p", "orders.get", []byte(`{}`))
+			var e *p2p.Error
+			if !errors.As(err, &e) {
+				t.Fatalf("expected a p2p error, got %v", err)
+			}
+
+			if e.Code != p2p.CodeNotFound || e.Message != "order 42 not found" || e.Details["id"] != "42" {
+				t.Errorf("expected the handler error rebuilt, got %+v", e)
+			}
+
+			_, err = client.Request("server-p2p", "orders.missing", []byte(`{}`))
+			if !errors.Is(err, p2p.ErrNoHandler) {
+				t.Errorf("expected a no handler error, got %v", err)
+			}
+
+			_, err = client.Request("server-p2p", "orders.fail", []byte(`{}`))
+			if !errors.As(err, &e) || e.Code != p2p.CodeUnknown || e.Message != "database down" {
+				t.Errorf("expected an unknown error with the handler message, got %v", err)
+			}
+		})
+	}
+}
+
+func TestError_NoFailover(t *testing.T) {
+	transport := &p2p.HttpTransport{}
+	from := p2p.New(p2p.Options{Transport: transport})
+
+	calls := make(chan struct{}, 2)
+	for i := 0; i < 2; i++ {
+		mx := http.NewServeMux()
+		srv := httptest.NewServer(mx)
+		defer srv.Close()
+
+		to := p2p.New(p2p.Options{Addr: srv.URL, Name: "target-p2p", Transport: transport})
+		to.HandleFunc(func(ctx context.Context, r *p2p.MessageRequest) ([]byte, error) {
+			calls <- struct{}{}
+			return nil, p2p.Errorf(p2p.CodeInvalidArgument, "bad order")
+		})
+		p2p.NewHttpServer(to, nil, "").Register(mx)
+
+		err := from.Discover(to.CurrentAddr())
+		if err != nil {
+			t.Fatalf("failed at discover: %s", err)
+		}
+	}
+
+	_, err := from.Request("target-p2p", "orders.create", []byte(`{}`))
+	var e *p2p.Error
+	if !errors.As(err, &e) || e.Code != p2p.CodeInvalidArgument {
+		t.Fatalf("expected an invalid argument error, got %v", err)
+	}
+
+	if len(calls) != 1 {
+		t.Errorf("expected handler errors not to fail over, got %d calls", len(calls))
+	}
+}
@@ -3,6 +3,8 @@ package p2p
 import (
 	"context"
 	"fmt"
+	"strings"
+	"sync"
 )
 
 type Handler interface {
@@ -15,27 +17,162 @@ func (h HandlerFunc) ServeP2P(ctx context.Context, r *MessageRequest) ([]byte, e
 	return h(ctx, r)
 }
 
+// ServeMux routes messages by subject. Subjects are dot separated tokens,
+// where a "*" token matches any single token and a trailing ">" matches one
+// or more tokens, so "orders.*.created" and "orders.>" both match
+// "orders.42.created". Literal tokens win over "*", which wins over ">".
 type ServeMux struct {
-	handlers map[string]Handler
+	mutex       sync.RWMutex
+	root        *subjectNode
+	middlewares []Middleware
+}
+
+type subjectNode struct {
+	handler  Handler
+	rest     Handler
+	children map[string]*subjectNode
+}
+
+type wildcardsKey struct{}
+
+// Wildcards returns the tokens matched by the "*" wildcards of the subject
+// the message was routed by, followed by the tail matched by ">" if any.
+func Wildcards(ctx context.Context) []string {
+	tokens, _ := ctx.Value(wildcardsKey{}).([]string)
+	return tokens
 }
 
 func NewServeMux() *ServeMux {
-	return &ServeMux{handlers: make(map[string]Handler)}
+	return &ServeMux{root: &subjectNode{}}
 }
 
+// Handle registers the handler for the subject, replacing any previous one.
+// It panics if the subject is not valid.
 func (mx *ServeMux) Handle(subject string, handler Handler) {
-	mx.handlers[subject] = handler
+	tokens := mustTokens(subject)
+
+	mx.mutex.Lock()
+	defer mx.mutex.Unlock()
+
+	r := mx.root
+	for i, token := range tokens {
+		if token == ">" && i == len(tokens)-1 {
+			r.rest = handler
+			return
+		}
+
+		next, ok := r.children[token]
+		if !ok {
+			if r.children == nil {
+				r.children = make(map[string]*subjectNode)
+			}
+			next = &subjectNode{}
+			r.children[token] = next
+		}
+		r = next
+	}
+
+	r.handler = handler
 }
 
 func (mx *ServeMux) HandleFunc(subject string, handler func(context.Context, *MessageRequest) ([]byte, error)) {
 	mx.Handle(subject, HandlerFunc(handler))
 }
 
-func (mx *ServeMux) ServeP2P(ctx context.Context, m *MessageRequest) ([]byte, error) {
-	h, ok := mx.handlers[m.Subject]
+// Remove unregisters the handler of the subject, reporting if there was one.
+func (mx *ServeMux) Remove(subject string) bool {
+	tokens := mustTokens(subject)
+
+	mx.mutex.Lock()
+	defer mx.mutex.Unlock()
+
+	return mx.root.remove(tokens)
+}
+
+func (r *subjectNode) remove(tokens []string) bool {
+	if len(tokens) == 0 {
+		ok := r.handler != nil
+		r.handler = nil
+		return ok
+	}
+
+	if len(tokens) == 1 && tokens[0] == ">" {
+		ok := r.rest != nil
+		r.rest = nil
+		return ok
+	}
+
+	next, ok := r.children[tokens[0]]
+	if !ok || !next.remove(tokens[1:]) {
+		return false
+	}
+
+	if next.handler == nil && next.rest == nil && len(next.children) == 0 {
+		delete(r.children, tokens[0])
+	}
+	return true
+}
+
+// match returns the most specific handler for the tokens along the tokens
+// captured by its wildcards.
+func (r *subjectNode) match(tokens []string, captured []string) (Handler, []string) {
+	if len(tokens) == 0 {
+		return r.handler, captured
+	}
+
+	next, ok := r.children[tokens[0]]
 	if ok {
-		return h.ServeP2P(ctx, m)
+		h, c := next.match(tokens[1:], captured)
+		if h != nil {
+			return h, c
+		}
+	}
+
+	next, ok = r.children["*"]
+	if ok {
+		h, c := next.match(tokens[1:], append(captured[:len(captured):len(captured)], tokens[0]))
+		if h != nil {
+			return h, c
+		}
+	}
+
+	if r.rest != nil {
+		return r.rest, append(captured[:len(captured):len(captured)], strings.Join(tokens, "."))
+	}
+
+	return nil, nil
+}
+
+func (mx *ServeMux) ServeP2P(ctx context.Context, m *MessageRequest) ([]byte, error) {
+	mx.mutex.RLock()
+	h, captured := mx.root.match(strings.Split(m.Subject, "."), nil)
+	middlewares := mx.middlewares
+	mx.mutex.RUnlock()
+
+	if h == nil {
+		h = HandlerFunc(unregistered)
 	}
 
-	return nil, fmt.Errorf("unregistered handler for subject '%s'", m.Subject)
+	if len(captured) > 0 {
+		ctx = context.WithValue(ctx, wildcardsKey{}, captured)
+	}
+	return Chain(h, middlewares...).ServeP2P(ctx, m)
+}
+
+func unregistered(ctx context.Context, m *MessageRequest) ([]byte, error) {
+	return nil, Errorf(CodeNoHandler, "unregistered handler for subject '%s'", m.Subject)
+}
+
+func mustTokens(subject string) []string {
+	tokens := strings.Split(subject, ".")
+	for i, token := range tokens {
+		if token == "" {
+			panic(fmt.Sprintf("p2p: empty token in subject '%s'", subject))
+		}
+
+		if token == ">" && i != len(tokens)-1 {
+			panic(fmt.Sprintf("p2p: '>' must be the last token of subject '%s'", subject))
+		}
+	}
+	return tokens
 }
@@ -0,0 +1,108 @@
+package p2p_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/yaien/p2p"
+)
+
+func named(name string) p2p.HandlerFunc {
+	return func(ctx context.Context, r *p2p.MessageRequest) ([]byte, error) {
+		return []byte(name + ":" + strings.Join(p2p.Wildcards(ctx), ",")), nil
+	}
+}
+
+func TestServeMux_Wildcards(t *testing.T) {
+	mx := p2p.NewServeMux()
+	mx.Handle("orders.created", named("exact"))
+	mx.Handle("orders.*.created", named("star"))
+	mx.Handle("orders.*.*", named("stars"))
+	mx.Handle("orders.>", named("rest"))
+	mx.Handle(">", named("all"))
+
+	for subject, expected := range map[string]string{
+		"orders.created":      "exact:",
+		"orders.42.created":   "star:42",
+		"orders.42.shipped":   "stars:42,shipped",
+		"orders.42":           "rest:42",
+		"orders.42.items.7":   "rest:42.items.7",
+		"orders":              "all:orders",
+		"invoices.42.created": "all:invoices.42.created",
+	} {
+		reply, err := mx.ServeP2P(context.Background(), &p2p.MessageRequest{Subject: subject})
+		if err != nil {
+			t.Errorf("failed serving %s: %s", subject, err)
+			continue
+		}
+
+		if string(reply) != expected {
+			t.Errorf("expected %s to be served by %s, got %s", subject, expected, reply)
+		}
+	}
+}
+
+func TestServeMux_Remove(t *testing.T) {
+	mx := p2p.NewServeMux()
+	mx.Handle("orders.*.created", named("star"))
+	mx.Handle("orders.>", named("rest"))
+
+	if !mx.Remove("orders.*.created") {
+		t.Fatal("expected the handler to be removed")
+	}
+
+	if mx.Remove("orders.*.created") {
+		t.Error("expected a second remove to report nothing removed")
+	}
+
+	reply, err := mx.ServeP2P(context.Background(), &p2p.MessageRequest{Subject: "orders.42.created"})
+	if err != nil || string(reply) != "rest:42.created" {
+		t.Errorf("expected fallback to the remaining handler, got %s %v", reply, err)
+	}
+
+	mx.Remove("orders.>")
+	_, err = mx.ServeP2P(context.Background(), &p2p.MessageRequest{Subject: "orders.42.created"})
+	if err == nil {
+		t.Error("expected no handler once all are removed")
+	}
+}
+
+func TestServeMux_InvalidSubject(t *testing.T) {
+	for _, subject := range []string{"", "orders..created", "orders.>.created"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected %q to be rejected", subject)
+				}
+			}()
+			p2p.NewServeMux().Handle(subject, named("invalid"))
+		}()
+	}
+}
+
+func TestServeMux_Concurrent(t *testing.T) {
+	mx := p2p.NewServeMux()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		subject := fmt.Sprintf("orders.%d", i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				mx.Handle(subject, named("exact"))
+				mx.Remove(subject)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				mx.ServeP2P(context.Background(), &p2p.MessageRequest{Subject: subject})
+			}
+		}()
+	}
+	wg.Wait()
+}
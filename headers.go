@@ -0,0 +1,124 @@
+package p2p
+
+import (
+	"context"
+	"sync"
+)
+
+// MessageOptions sets what travels along a message besides its body.
+type MessageOptions struct {
+	Headers     map[string]string
+	ContentType string
+	// Key lets the balancer pick the peer, as in RequestKey, instead of the
+	// subject.
+	Key string
+}
+
+type headersKey struct{}
+
+type replySinkKey struct{}
+
+type replyHeadersKey struct{}
+
+// headerSet collects headers that may be set concurrently.
+type headerSet struct {
+	mutex  sync.Mutex
+	values map[string]string
+}
+
+func (h *headerSet) set(key, value string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.values == nil {
+		h.values = make(map[string]string)
+	}
+	h.values[key] = value
+}
+
+func (h *headerSet) replace(values map[string]string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.values = values
+}
+
+func (h *headerSet) get() map[string]string {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.values
+}
+
+// withOptions returns ctx carrying the headers and content type of opts to
+// the transport.
+func withOptions(ctx context.Context, opts MessageOptions) context.Context {
+	if opts.ContentType != "" {
+		ctx = WithContentType(ctx, opts.ContentType)
+	}
+	if len(opts.Headers) > 0 {
+		ctx = context.WithValue(ctx, headersKey{}, opts.Headers)
+	}
+	return ctx
+}
+
+func requestHeaders(ctx context.Context) map[string]string {
+	v, _ := ctx.Value(headersKey{}).(map[string]string)
+	return v
+}
+
+// setReplyHeaders hands the headers a transport received along a reply to
+// the RequestMessage waiting for them.
+func setReplyHeaders(ctx context.Context, headers map[string]string) {
+	sink, _ := ctx.Value(replySinkKey{}).(*headerSet)
+	if sink != nil {
+		sink.replace(headers)
+	}
+}
+
+// SetReplyHeader sets a header of the reply to the message handled with ctx.
+func SetReplyHeader(ctx context.Context, key, value string) {
+	h, _ := ctx.Value(replyHeadersKey{}).(*headerSet)
+	if h != nil {
+		h.set(key, value)
+	}
+}
+
+// serveMessage serves a message received by a server, replying with the
+// headers set by the handler. What was set for outgoing messages on ctx, as
+// it happens with the memory transport, is cleared so requests made by the
+// handler don't inherit it.
+func (p *P2P) serveMessage(ctx context.Context, r *MessageRequest) (*MessageResponse, error) {
+	ctx = context.WithValue(ctx, contentTypeKey{}, "")
+	ctx = context.WithValue(ctx, headersKey{}, map[string]string(nil))
+	ctx = context.WithValue(ctx, replySinkKey{}, (*headerSet)(nil))
+
+	reply := &headerSet{}
+	ctx = context.WithValue(ctx, replyHeadersKey{}, reply)
+
+	body, err := p.ServeP2P(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	return &MessageResponse{Body: body, Headers: reply.get()}, nil
+}
+
+// RequestMessage is like RequestContext but sends the message along opts and
+// returns the reply with the headers set by the handler.
+func (p *P2P) RequestMessage(ctx context.Context, pattern string, subject string, body []byte, opts MessageOptions) (*MessageResponse, error) {
+	key := opts.Key
+	if key == "" {
+		key = subject
+	}
+
+	sink := &headerSet{}
+	ctx = context.WithValue(withOptions(ctx, opts), replySinkKey{}, sink)
+
+	reply, err := p.RequestKeyContext(ctx, pattern, key, subject, body)
+	if err != nil {
+		return nil, err
+	}
+	return &MessageResponse{Body: reply, Headers: sink.get()}, nil
+}
+
+// BroadcastMessage is like BroadcastContext but sends the message along opts.
+func (p *P2P) BroadcastMessage(ctx context.Context, pattern string, subject string, body []byte, opts MessageOptions) error {
+	return p.BroadcastContext(withOptions(ctx, opts), pattern, subject, body)
+}
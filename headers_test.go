@@ -0,0 +1,93 @@
+package p2p_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/yaien/p2p"
+	"github.com/yaien/p2p/p2ptest"
+)
+
+func TestRequestMessage_Headers(t *testing.T) {
+	for name, f := range map[string]p2ptest.Factory{
+		"Http":      httpFactory(),
+		"Grpc":      grpcFactory(),
+		"WebSocket": webSocketFactory(),
+		"Tcp":       tcpFactory(),
+		"Memory":    memoryFactory(),
+	} {
+		f := f
+		t.Run(name, func(t *testing.T) {
+			client, mx := typedNodes(t, f)
+
+			received := make(chan map[string]string, 1)
+			mx.HandleFunc("traced", func(ctx context.Context, r *p2p.MessageRequest) ([]byte, error) {
+				received <- r.Headers
+				p2p.SetReplyHeader(ctx, "correlation-id", r.Headers["trace-id"])
+				return []byte(`{}`), nil
+			})
+
+			opts := p2p.MessageOptions{Headers: map[string]string{"trace-id": "abc", "tenant": "acme"}}
+			res, err := client.RequestMessage(context.Background(), "server-p2p", "traced", []byte(`{}`), opts)
+			if err != nil {
+				t.Fatalf("failed at request: %s", err)
+			}
+
+			headers := <-received
+			if headers["trace-id"] != "abc" || headers["tenant"] != "acme" {
+				t.Errorf("expected the request headers to reach the handler, got %v", headers)
+			}
+
+			if res.Headers["correlation-id"] != "abc" {
+				t.Errorf("expected the reply headers to reach the caller, got %v", res.Headers)
+			}
+
+			err = client.BroadcastMessage(context.Background(), "server-p2p", "traced", []byte(`{}`), p2p.MessageOptions{Headers: map[string]string{"trace-id": "def"}})
+			if err != nil {
+				t.Fatalf("failed at broadcast: %s", err)
+			}
+
+			headers = <-received
+			if headers["trace-id"] != "def" {
+				t.Errorf("expected the broadcast headers to reach the handler, got %v", headers)
+			}
+		})
+	}
+}
+
+func TestRequestMessage_NotInherited(t *testing.T) {
+	clock := p2p.NewManualClock(time.Now())
+	network := p2p.NewNetwork(p2p.NetworkOptions{Clock: clock})
+	nodes := memoryNodes(network, clock, 3, p2p.Options{})
+	first, second, third := nodes[0], nodes[1], nodes[2]
+
+	err := first.Discover(second.CurrentAddr())
+	if err != nil {
+		t.Fatalf("failed at discover: %s", err)
+	}
+
+	err = second.Discover(third.CurrentAddr())
+	if err != nil {
+		t.Fatalf("failed at discover: %s", err)
+	}
+
+	received := make(chan map[string]string, 1)
+	third.HandleFunc(func(ctx context.Context, r *p2p.MessageRequest) ([]byte, error) {
+		received <- r.Headers
+		return []byte(`{}`), nil
+	})
+
+	second.HandleFunc(func(ctx context.Context, r *p2p.MessageRequest) ([]byte, error) {
+		return second.RequestContext(ctx, "node-2", "inner", []byte(`{}`))
+	})
+
+	_, err = first.RequestMessage(context.Background(), "node-1", "outer", []byte(`{}`), p2p.MessageOptions{Headers: map[string]string{"trace-id": "abc"}})
+	if err != nil {
+		t.Fatalf("failed at request: %s", err)
+	}
+
+	if headers := <-received; len(headers) != 0 {
+		t.Errorf("expected nested requests not to inherit headers, got %v", headers)
+	}
+}
@@ -0,0 +1,193 @@
+package p2p
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+var ErrInvalidProof = errors.New("invalid peer proof")
+
+const (
+	proofConnect = "connect"
+	proofMessage = "message"
+	proofLeave   = "leave"
+)
+
+// Identity is the ed25519 keypair of a node, its peer id is derived from the
+// public key so it survives restarts and can't be claimed by other nodes.
+type Identity struct {
+	key ed25519.PrivateKey
+}
+
+func GenerateIdentity() (*Identity, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed generating key: %w", err)
+	}
+	return &Identity{key: key}, nil
+}
+
+func LoadIdentity(path string) (*Identity, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed reading identity: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no pem block found in %s", path)
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed parsing identity: %w", err)
+	}
+
+	ed, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("unexpected %T identity key", key)
+	}
+
+	return &Identity{key: ed}, nil
+}
+
+// LoadOrCreateIdentity loads the identity at path, generating and saving a
+// new one when the file does not exist.
+func LoadOrCreateIdentity(path string) (*Identity, error) {
+	id, err := LoadIdentity(path)
+	if err == nil {
+		return id, nil
+	}
+
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	id, err = GenerateIdentity()
+	if err != nil {
+		return nil, err
+	}
+
+	err = id.Save(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return id, nil
+}
+
+func (i *Identity) Save(path string) error {
+	der, err := x509.MarshalPKCS8PrivateKey(i.key)
+	if err != nil {
+		return fmt.Errorf("failed encoding identity: %w", err)
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return fmt.Errorf("failed creating identity dir: %w", err)
+	}
+
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	err = os.WriteFile(path, data, 0600)
+	if err != nil {
+		return fmt.Errorf("failed writing identity: %w", err)
+	}
+
+	return nil
+}
+
+func (i *Identity) PublicKey() ed25519.PublicKey {
+	return i.key.Public().(ed25519.PublicKey)
+}
+
+func (i *Identity) Id() string {
+	return PeerId(i.PublicKey())
+}
+
+// PeerId derives a peer id from its public key.
+func PeerId(key ed25519.PublicKey) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:16])
+}
+
+// proof returns a copy of peer carrying a signature of the action, so the
+// receiver can check the sender owns the key behind the peer id.
+func (i *Identity) proof(peer *Peer, now time.Time, action, target, subject string, body []byte) *Peer {
+	from := proto.Clone(peer).(*Peer)
+	from.Proof = &Proof{Timestamp: now.Unix()}
+	from.Proof.Signature = ed25519.Sign(i.key, proofPayload(from, action, target, subject, body))
+	return from
+}
+
+func verifyProof(from *Peer, now time.Time, action, target, subject string, body []byte, skew time.Duration) error {
+	if from == nil || from.Proof == nil {
+		return fmt.Errorf("%w: missing proof", ErrInvalidProof)
+	}
+
+	if !validPeerId(from) {
+		return fmt.Errorf("%w: id does not match the public key", ErrInvalidProof)
+	}
+
+	at := time.Unix(from.Proof.Timestamp, 0)
+	if now.Sub(at) > skew || at.Sub(now) > skew {
+		return fmt.Errorf("%w: expired", ErrInvalidProof)
+	}
+
+	if !ed25519.Verify(from.PublicKey, proofPayload(from, action, target, subject, body), from.Proof.Signature) {
+		return fmt.Errorf("%w: bad signature", ErrInvalidProof)
+	}
+
+	return nil
+}
+
+// validPeerId reports whether the peer id is the one derived from its key.
+func validPeerId(peer *Peer) bool {
+	return len(peer.PublicKey) == ed25519.PublicKeySize && PeerId(peer.PublicKey) == peer.Id
+}
+
+// proofTarget reduces the address a connect proof is addressed to, to the
+// host:port or socket it reaches, so http://host:port and grpc://host:port
+// bind the same node.
+func proofTarget(addr string) string {
+	if strings.HasPrefix(addr, unixScheme) {
+		return addr
+	}
+
+	scheme, rest, ok := strings.Cut(addr, "://")
+	if ok {
+		if strings.HasSuffix(scheme, "+unix") {
+			return unixScheme + rest
+		}
+		addr = rest
+	}
+
+	host, _, _ := strings.Cut(addr, "/")
+	return strings.ToLower(host)
+}
+
+// proofPayload covers the whole peer record but its proof, so none of the
+// addresses, endpoints or labels it announces can be swapped on a replay.
+func proofPayload(from *Peer, action, target, subject string, body []byte) []byte {
+	record := proto.Clone(from).(*Peer)
+	record.Proof = nil
+	data, _ := proto.MarshalOptions{Deterministic: true}.Marshal(record)
+
+	peer := sha256.Sum256(data)
+	digest := sha256.Sum256(body)
+	timestamp := strconv.FormatInt(from.Proof.Timestamp, 10)
+	return []byte(fmt.Sprintf("p2p-proof\n%s\n%s\n%s\n%s\n%x\n%x", action, target, subject, timestamp, peer, digest))
+}
@@ -0,0 +1,179 @@
+package p2p_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/yaien/p2p"
+)
+
+func TestIdentity_LoadOrCreate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "p2p", "identity.pem")
+
+	created, err := p2p.LoadOrCreateIdentity(path)
+	if err != nil {
+		t.Fatalf("failed creating identity: %s", err)
+	}
+
+	loaded, err := p2p.LoadOrCreateIdentity(path)
+	if err != nil {
+		t.Fatalf("failed loading identity: %s", err)
+	}
+
+	if created.Id() != loaded.Id() {
+		t.Errorf("expected id %s to survive reload, got %s", created.Id(), loaded.Id())
+	}
+
+	p := p2p.New(p2p.Options{Identity: loaded})
+	if p.State().Current.Id != created.Id() {
+		t.Errorf("expected peer id %s, got %s", created.Id(), p.State().Current.Id)
+	}
+}
+
+func TestP2P_Http_Impersonation(t *testing.T) {
+	transport := &p2p.HttpTransport{}
+
+	mx := http.NewServeMux()
+	srv := httptest.NewServer(mx)
+	defer srv.Close()
+
+	to := p2p.New(p2p.Options{Addr: srv.URL, Name: "target-p2p", Transport: transport})
+	p2p.NewHttpServer(to, nil, "").Register(mx)
+
+	victim := p2p.New(p2p.Options{Name: "victim-p2p", Transport: transport})
+	err := victim.Discover(to.CurrentAddr())
+	if err != nil {
+		t.Fatalf("failed at discover: %s", err)
+	}
+
+	forged := victim.State().Current
+	_, err = transport.Connect(context.Background(), forged, to.CurrentAddr())
+	if err == nil {
+		t.Fatal("expected connect without a proof to fail")
+	}
+
+	intruder := p2p.New(p2p.Options{Name: "intruder-p2p", Transport: transport})
+	forged = intruder.State().Current
+	forged.Id = victim.State().Current.Id
+	err = transport.Leave(context.Background(), forged, to.State().Current)
+	if err == nil {
+		t.Fatal("expected leave claiming another peer id to fail")
+	}
+
+	if len(to.Peers()) != 1 {
+		t.Errorf("expected target to keep the victim, got %d peers", len(to.Peers()))
+	}
+}
+
+func TestP2P_Http_ConnectReplay(t *testing.T) {
+	transport := &p2p.HttpTransport{}
+
+	var captured []byte
+	mx := http.NewServeMux()
+	srvA := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/p2p/connect" {
+			captured, _ = io.ReadAll(r.Body)
+			r.Body = io.NopCloser(bytes.NewReader(captured))
+		}
+		mx.ServeHTTP(w, r)
+	}))
+	defer srvA.Close()
+
+	a := p2p.New(p2p.Options{Addr: srvA.URL, Name: "a-p2p", Transport: transport})
+	p2p.NewHttpServer(a, nil, "").Register(mx)
+
+	mxB := http.NewServeMux()
+	srvB := httptest.NewServer(mxB)
+	defer srvB.Close()
+
+	b := p2p.New(p2p.Options{Addr: srvB.URL, Name: "b-p2p", Transport: transport})
+	p2p.NewHttpServer(b, nil, "").Register(mxB)
+
+	from := p2p.New(p2p.Options{Name: "from-p2p", Transport: transport})
+	err := from.Discover(a.CurrentAddr())
+	if err != nil {
+		t.Fatalf("failed at discover: %s", err)
+	}
+
+	var proved p2p.Peer
+	err = json.Unmarshal(captured, &proved)
+	if err != nil {
+		t.Fatalf("failed decoding captured connect: %s", err)
+	}
+
+	_, err = transport.Connect(context.Background(), &proved, b.CurrentAddr())
+	if err == nil {
+		t.Fatal("expected a connect proof addressed to another node to be rejected")
+	}
+}
+
+func TestP2P_Http_ConnectEndpointsReplay(t *testing.T) {
+	transport := &p2p.HttpTransport{}
+
+	var captured []byte
+	mx := http.NewServeMux()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/p2p/connect" && captured == nil {
+			captured, _ = io.ReadAll(r.Body)
+			r.Body = io.NopCloser(bytes.NewReader(captured))
+		}
+		mx.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	a := p2p.New(p2p.Options{Addr: srv.URL, Name: "a-p2p", Transport: transport})
+	p2p.NewHttpServer(a, nil, "").Register(mx)
+
+	from := p2p.New(p2p.Options{Name: "from-p2p", Transport: transport})
+	err := from.Discover(a.CurrentAddr())
+	if err != nil {
+		t.Fatalf("failed at discover: %s", err)
+	}
+
+	var proved p2p.Peer
+	err = json.Unmarshal(captured, &proved)
+	if err != nil {
+		t.Fatalf("failed decoding captured connect: %s", err)
+	}
+
+	_, err = transport.Connect(context.Background(), &proved, a.CurrentAddr())
+	if err != nil {
+		t.Fatalf("expected the untouched connect to be accepted: %s", err)
+	}
+
+	proved.Endpoints = []*p2p.Endpoint{{Scheme: "http", Host: "attacker", Port: 80}}
+	_, err = transport.Connect(context.Background(), &proved, a.CurrentAddr())
+	if err == nil {
+		t.Fatal("expected a connect replayed with other endpoints to be rejected")
+	}
+}
+
+func TestP2P_Http_GossipedId(t *testing.T) {
+	legit := p2p.New(p2p.Options{Addr: "http://legit", Name: "legit-p2p"})
+	other := p2p.New(p2p.Options{Addr: "http://other", Name: "other-p2p"})
+
+	forged := other.State().Current
+	forged.Id = legit.State().Current.Id + "0"
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(&p2p.State{Current: legit.State().Current, Peers: []*p2p.Peer{forged}})
+	}))
+	defer srv.Close()
+
+	client := p2p.New(p2p.Options{Name: "client-p2p", Transport: &p2p.HttpTransport{}})
+	err := client.Discover(srv.URL)
+	if err != nil {
+		t.Fatalf("failed at discover: %s", err)
+	}
+
+	peers := client.Peers()
+	if len(peers) != 1 || peers[0].Id != legit.State().Current.Id {
+		t.Errorf("expected only the peer matching its public key to be stored, got %v", peers)
+	}
+}
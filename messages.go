@@ -1,46 +1,174 @@
 package p2p
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"log"
 	"path"
+	"sort"
 )
 
 var ErrNoneMatchedPeers = errors.New("none peer matched the pattern")
 
 func (p *P2P) Broadcast(pattern string, subject string, body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+	return p.BroadcastContext(ctx, pattern, subject, body)
+}
 
-	for _, peer := range p.peers {
-		matched, err := path.Match(pattern, peer.Name)
-		if err != nil {
-			return fmt.Errorf("failed at pattern match: %w", err)
-		}
+func (p *P2P) BroadcastContext(ctx context.Context, pattern string, subject string, body []byte) error {
+	peers, err := p.match(globMatcher(pattern))
+	if err != nil {
+		return err
+	}
 
-		if !matched {
-			continue
-		}
+	p.broadcast(ctx, peers, subject, body)
+	return nil
+}
+
+func (p *P2P) BroadcastSelector(selector string, subject string, body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+	return p.BroadcastSelectorContext(ctx, selector, subject, body)
+}
 
-		p.transport.Send(p.current, peer, subject, body)
+func (p *P2P) BroadcastSelectorContext(ctx context.Context, selector string, subject string, body []byte) error {
+	matcher, err := selectorMatcher(selector)
+	if err != nil {
+		return err
+	}
 
+	peers, err := p.match(matcher)
+	if err != nil {
+		return err
 	}
 
+	p.broadcast(ctx, peers, subject, body)
 	return nil
 }
 
 func (p *P2P) Request(pattern string, subj string, body []byte) ([]byte, error) {
-	for _, peer := range p.peers {
-		matched, err := path.Match(pattern, peer.Name)
-		if err != nil {
-			return nil, fmt.Errorf("failed at pattern match: %w", err)
+	return p.RequestKey(pattern, subj, subj, body)
+}
+
+func (p *P2P) RequestContext(ctx context.Context, pattern string, subj string, body []byte) ([]byte, error) {
+	return p.RequestKeyContext(ctx, pattern, subj, subj, body)
+}
+
+// RequestKey is like Request but lets the balancer pick the peer by key, so a
+// ConsistentHashBalancer sends the same key to the same peer.
+func (p *P2P) RequestKey(pattern string, key string, subj string, body []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+	return p.RequestKeyContext(ctx, pattern, key, subj, body)
+}
+
+func (p *P2P) RequestKeyContext(ctx context.Context, pattern string, key string, subj string, body []byte) ([]byte, error) {
+	peers, err := p.match(globMatcher(pattern))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(peers) == 0 {
+		return nil, fmt.Errorf("%w %s", ErrNoneMatchedPeers, pattern)
+	}
+
+	return p.request(ctx, peers, key, subj, body)
+}
+
+func (p *P2P) RequestSelector(selector string, subj string, body []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+	return p.RequestSelectorContext(ctx, selector, subj, body)
+}
+
+func (p *P2P) RequestSelectorContext(ctx context.Context, selector string, subj string, body []byte) ([]byte, error) {
+	matcher, err := selectorMatcher(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	peers, err := p.match(matcher)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(peers) == 0 {
+		return nil, fmt.Errorf("%w %s", ErrNoneMatchedPeers, selector)
+	}
+
+	return p.request(ctx, peers, subj, subj, body)
+}
+
+func (p *P2P) request(ctx context.Context, peers []*Peer, key string, subj string, body []byte) ([]byte, error) {
+	candidates := p.balancer.Order(key, peers)
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Status < candidates[j].Status
+	})
+
+	var err error
+	for _, peer := range candidates {
+		p.balancer.Begin(peer)
+		start := p.clock.Now()
+		var reply []byte
+		reply, err = p.transport.Send(ctx, p.sign(proofMessage, peer.Id, subj, body), peer, subj, body)
+		p.balancer.End(peer, p.clock.Now().Sub(start), err)
+		if err == nil {
+			return reply, nil
+		}
+
+		var e *Error
+		if ctx.Err() != nil || errors.As(err, &e) && !e.temporary() {
+			break
 		}
 
-		if !matched {
-			continue
+		log.Println("request to", peer.Addr, "failed:", err)
+	}
+
+	return nil, fmt.Errorf("failed at request to %d peers: %w", len(candidates), err)
+}
+
+func (p *P2P) broadcast(ctx context.Context, peers []*Peer, subject string, body []byte) {
+	for _, peer := range peers {
+		p.transport.Send(ctx, p.sign(proofMessage, peer.Id, subject, body), peer, subject, body)
+	}
+}
+
+type matcher func(peer *Peer) (bool, error)
+
+func globMatcher(pattern string) matcher {
+	return func(peer *Peer) (bool, error) {
+		matched, err := path.Match(pattern, peer.Name)
+		if err != nil {
+			return false, fmt.Errorf("failed at pattern match: %w", err)
 		}
+		return matched, nil
+	}
+}
 
-		return p.transport.Send(p.current, peer, subj, body)
+func selectorMatcher(selector string) (matcher, error) {
+	s, err := ParseSelector(selector)
+	if err != nil {
+		return nil, fmt.Errorf("failed at parsing selector: %w", err)
 	}
 
-	return nil, fmt.Errorf("%w %s", ErrNoneMatchedPeers, pattern)
+	return func(peer *Peer) (bool, error) {
+		return s.Matches(peer.Labels), nil
+	}, nil
+}
 
+func (p *P2P) match(m matcher) ([]*Peer, error) {
+	var peers []*Peer
+	for _, peer := range p.Peers() {
+		matched, err := m(peer)
+		if err != nil {
+			return nil, err
+		}
+
+		if matched {
+			peers = append(peers, peer)
+		}
+	}
+	return peers, nil
 }
@@ -2,10 +2,13 @@ package p2p_test
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/yaien/p2p"
 	"golang.org/x/net/nettest"
@@ -107,7 +110,7 @@ func TestP2P_Grpc_Broadcast(t *testing.T) {
 
 	to.Handle(handler)
 
-	srv := p2p.NewGrpcServer(to, nil)
+	srv := p2p.NewGrpcServer(to, nil, "")
 	go srv.Serve(lis)
 	defer srv.Close()
 
@@ -144,7 +147,7 @@ func TestP2P_Grpc_Request(t *testing.T) {
 
 	to.Handle(handler)
 
-	srv := p2p.NewGrpcServer(to, nil)
+	srv := p2p.NewGrpcServer(to, nil, "")
 	go srv.Serve(lis)
 	defer srv.Close()
 
@@ -164,3 +167,129 @@ func TestP2P_Grpc_Request(t *testing.T) {
 
 	t.Log("data received:", string(data))
 }
+
+func TestP2P_Http_RequestAll(t *testing.T) {
+	transport := &p2p.HttpTransport{}
+	from := p2p.New(p2p.Options{Transport: transport})
+
+	for i, delay := range []time.Duration{0, 0, 500 * time.Millisecond} {
+		delay := delay
+		mx := http.NewServeMux()
+		srv := httptest.NewServer(mx)
+		defer srv.Close()
+
+		to := p2p.New(p2p.Options{Addr: srv.URL, Name: fmt.Sprintf("target-%d", i), Transport: transport})
+		to.HandleFunc(func(ctx context.Context, r *p2p.MessageRequest) ([]byte, error) {
+			time.Sleep(delay)
+			return []byte(`{ "message": "received" }`), nil
+		})
+		p2p.NewHttpServer(to, nil, "").Register(mx)
+
+		err := from.Discover(to.CurrentAddr())
+		if err != nil {
+			t.Fatalf("failed at discover: %s", err)
+		}
+	}
+
+	replies, err := from.RequestAll(context.Background(), "target-*", "message", []byte(`{}`), p2p.WithPeerTimeout(100*time.Millisecond))
+	if err != nil {
+		t.Fatalf("failed at request all: %s", err)
+	}
+
+	if len(replies) != 3 || replies.Successes() != 2 {
+		t.Errorf("expected 3 replies with 2 successes, got %d with %d", len(replies), replies.Successes())
+	}
+
+	for _, err := range replies.Errors() {
+		if !errors.Is(err, p2p.ErrPeerTimeout) {
+			t.Errorf("expected peer timeout, got %s", err)
+		}
+	}
+
+	replies, err = from.RequestAll(context.Background(), "target-*", "message", []byte(`{}`), p2p.WithQuorum(2))
+	if err != nil {
+		t.Fatalf("failed at request all with quorum: %s", err)
+	}
+
+	if replies.Successes() != 2 {
+		t.Errorf("expected to return on 2 successes, got %d", replies.Successes())
+	}
+}
+
+func TestP2P_Http_RequestContext(t *testing.T) {
+	transport := &p2p.HttpTransport{}
+	from := p2p.New(p2p.Options{Transport: transport})
+
+	mx := http.NewServeMux()
+	srv := httptest.NewServer(mx)
+	defer srv.Close()
+
+	to := p2p.New(p2p.Options{Addr: srv.URL, Name: "target-p2p", Transport: transport})
+	p2p.NewHttpServer(to, nil, "").Register(mx)
+
+	deadlines := make(chan bool, 1)
+	to.HandleFunc(func(ctx context.Context, r *p2p.MessageRequest) ([]byte, error) {
+		_, ok := ctx.Deadline()
+		deadlines <- ok
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	err := from.Discover(to.CurrentAddr())
+	if err != nil {
+		t.Fatalf("failed at discover: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	_, err = from.RequestContext(ctx, "target-p2p", "message", []byte(`{}`))
+	if err == nil {
+		t.Fatal("expected request to fail after the deadline")
+	}
+
+	if !<-deadlines {
+		t.Error("expected the handler context to carry the caller deadline")
+	}
+}
+
+func TestP2P_Grpc_RequestContext(t *testing.T) {
+	transport := &p2p.GrpcTransport{}
+	from := p2p.New(p2p.Options{Transport: transport})
+
+	lis, err := nettest.NewLocalListener("tcp")
+	if err != nil {
+		t.Fatalf("failed creating testing listener: %s", err)
+	}
+
+	to := p2p.New(p2p.Options{Addr: lis.Addr().String(), Name: "target-p2p", Transport: transport})
+
+	deadlines := make(chan bool, 1)
+	to.HandleFunc(func(ctx context.Context, r *p2p.MessageRequest) ([]byte, error) {
+		_, ok := ctx.Deadline()
+		deadlines <- ok
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	srv := p2p.NewGrpcServer(to, nil, "")
+	go srv.Serve(lis)
+	defer srv.Close()
+
+	err = from.Discover(to.CurrentAddr())
+	if err != nil {
+		t.Fatalf("failed at discover: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	_, err = from.RequestContext(ctx, "target-p2p", "message", []byte(`{}`))
+	if err == nil {
+		t.Fatal("expected request to fail after the deadline")
+	}
+
+	if !<-deadlines {
+		t.Error("expected the handler context to carry the caller deadline")
+	}
+}
@@ -0,0 +1,165 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+var ErrRateLimited = errors.New("rate limited")
+
+// Middleware wraps a handler to run code around it.
+type Middleware func(Handler) Handler
+
+// Use appends middlewares run around every handler of the mux, the first
+// one being the outermost. They also see messages with no handler.
+func (mx *ServeMux) Use(middlewares ...Middleware) {
+	mx.mutex.Lock()
+	defer mx.mutex.Unlock()
+	mx.middlewares = append(mx.middlewares, middlewares...)
+}
+
+// Chain wraps h with the middlewares, the first one being the outermost.
+func Chain(h Handler, middlewares ...Middleware) Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
+
+// Recover turns a panicking handler into an error reply instead of killing
+// the server request or stream it runs on.
+func Recover() Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, r *MessageRequest) (data []byte, err error) {
+			defer func() {
+				v := recover()
+				if v != nil {
+					log.Printf("panic serving subject '%s': %v\n%s", r.Subject, v, debug.Stack())
+					err = Errorf(CodeInternal, "panic serving subject '%s': %v", r.Subject, v)
+				}
+			}()
+			return next.ServeP2P(ctx, r)
+		})
+	}
+}
+
+// Timeout bounds the handler to d, replying with an error once it expires
+// even when the handler ignores its context. Wrap a single handler with it
+// to set a timeout per subject.
+func Timeout(d time.Duration) Middleware {
+	return func(next Handler) Handler {
+		// the handler runs in its own goroutine, out of reach of an outer
+		// Recover, so its panics are recovered there and replied as errors
+		next = Recover()(next)
+		return HandlerFunc(func(ctx context.Context, r *MessageRequest) ([]byte, error) {
+			ctx, cancel := context.WithTimeout(ctx, d)
+			defer cancel()
+
+			type result struct {
+				data []byte
+				err  error
+			}
+
+			done := make(chan result, 1)
+			go func() {
+				data, err := next.ServeP2P(ctx, r)
+				done <- result{data, err}
+			}()
+
+			select {
+			case res := <-done:
+				return res.data, res.err
+			case <-ctx.Done():
+				return nil, fmt.Errorf("handler for subject '%s' stopped: %w", r.Subject, ctx.Err())
+			}
+		})
+	}
+}
+
+// Logger logs the subject, sender, duration and error of every message, to
+// the standard logger when l is nil.
+func Logger(l *log.Logger) Middleware {
+	if l == nil {
+		l = log.Default()
+	}
+
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, r *MessageRequest) ([]byte, error) {
+			start := time.Now()
+			data, err := next.ServeP2P(ctx, r)
+			if err != nil {
+				l.Printf("message %s from %s failed after %s: %s", r.Subject, r.From.GetId(), time.Since(start), err)
+			} else {
+				l.Printf("message %s from %s served in %s", r.Subject, r.From.GetId(), time.Since(start))
+			}
+			return data, err
+		})
+	}
+}
+
+// RateLimit allows every peer rate messages per second, with bursts of up to
+// burst messages, replying ErrRateLimited past that.
+func RateLimit(rate float64, burst int) Middleware {
+	limiter := &limiter{rate: rate, burst: float64(burst), buckets: make(map[string]*bucket)}
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, r *MessageRequest) ([]byte, error) {
+			if !limiter.allow(r.From.GetId(), time.Now()) {
+				return nil, fmt.Errorf("message %s from %s: %w", r.Subject, r.From.GetId(), ErrRateLimited)
+			}
+			return next.ServeP2P(ctx, r)
+		})
+	}
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// limiter keeps a token bucket per peer.
+type limiter struct {
+	rate    float64
+	burst   float64
+	mutex   sync.Mutex
+	buckets map[string]*bucket
+}
+
+func (l *limiter) allow(id string, now time.Time) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	b, ok := l.buckets[id]
+	if !ok {
+		if len(l.buckets) >= 1024 {
+			l.sweep(now)
+		}
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[id] = b
+	}
+
+	b.tokens += now.Sub(b.last).Seconds() * l.rate
+	if b.tokens > l.burst {
+		b.tokens = l.burst
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// sweep drops the buckets refilled by now, which behave as new ones.
+func (l *limiter) sweep(now time.Time) {
+	for id, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, id)
+		}
+	}
+}
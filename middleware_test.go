@@ -0,0 +1,149 @@
+package p2p_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yaien/p2p"
+)
+
+func TestServeMux_Use(t *testing.T) {
+	var order []string
+	trace := func(name string) p2p.Middleware {
+		return func(next p2p.Handler) p2p.Handler {
+			return p2p.HandlerFunc(func(ctx context.Context, r *p2p.MessageRequest) ([]byte, error) {
+				order = append(order, name)
+				return next.ServeP2P(ctx, r)
+			})
+		}
+	}
+
+	mx := p2p.NewServeMux()
+	mx.Use(trace("outer"), trace("inner"))
+	mx.Handle("echo", named("echo"))
+
+	_, err := mx.ServeP2P(context.Background(), &p2p.MessageRequest{Subject: "echo"})
+	if err != nil {
+		t.Fatalf("failed serving: %s", err)
+	}
+
+	_, err = mx.ServeP2P(context.Background(), &p2p.MessageRequest{Subject: "missing"})
+	if err == nil {
+		t.Fatal("expected an error for an unregistered subject")
+	}
+
+	if strings.Join(order, ",") != "outer,inner,outer,inner" {
+		t.Errorf("unexpected middleware order: %v", order)
+	}
+}
+
+func TestRecover_Http(t *testing.T) {
+	server := p2p.New(p2p.Options{Name: "server-p2p", Transport: &p2p.HttpTransport{}})
+	mx := p2p.NewServeMux()
+	mx.Use(p2p.Recover())
+	mx.HandleFunc("panic", func(ctx context.Context, r *p2p.MessageRequest) ([]byte, error) {
+		panic("boom")
+	})
+	mx.HandleFunc("echo", func(ctx context.Context, r *p2p.MessageRequest) ([]byte, error) {
+		return r.Body, nil
+	})
+	server.Handle(mx)
+	addr := httpFactory().Serve(t, server, "")
+
+	client := p2p.New(p2p.Options{Name: "client-p2p", Transport: &p2p.HttpTransport{}})
+	err := client.Discover(addr)
+	if err != nil {
+		t.Fatalf("failed at discover: %s", err)
+	}
+
+	_, err = client.Request("server-p2p", "panic", []byte(`{}`))
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("expected the panic as a reply error, got %v", err)
+	}
+
+	_, err = client.Request("server-p2p", "echo", []byte(`{}`))
+	if err != nil {
+		t.Errorf("expected the server to keep serving, got %s", err)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	stuck := p2p.HandlerFunc(func(ctx context.Context, r *p2p.MessageRequest) ([]byte, error) {
+		time.Sleep(time.Second)
+		return nil, nil
+	})
+
+	mx := p2p.NewServeMux()
+	mx.Handle("slow", p2p.Timeout(20*time.Millisecond)(stuck))
+	mx.Handle("echo", named("echo"))
+
+	start := time.Now()
+	_, err := mx.ServeP2P(context.Background(), &p2p.MessageRequest{Subject: "slow"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected a deadline error, got %v", err)
+	}
+
+	if time.Since(start) > 500*time.Millisecond {
+		t.Errorf("expected the timeout to return early, took %s", time.Since(start))
+	}
+
+	_, err = mx.ServeP2P(context.Background(), &p2p.MessageRequest{Subject: "echo"})
+	if err != nil {
+		t.Errorf("expected other subjects unbounded, got %s", err)
+	}
+
+	mx.Handle("panic", p2p.Timeout(time.Second)(p2p.HandlerFunc(func(ctx context.Context, r *p2p.MessageRequest) ([]byte, error) {
+		panic("exploded")
+	})))
+
+	_, err = mx.ServeP2P(context.Background(), &p2p.MessageRequest{Subject: "panic"})
+	var e *p2p.Error
+	if !errors.As(err, &e) || e.Code != p2p.CodeInternal {
+		t.Errorf("expected the panic to be replied as an internal error, got %v", err)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	mx := p2p.NewServeMux()
+	mx.Use(p2p.RateLimit(1, 2))
+	mx.Handle("echo", named("echo"))
+
+	send := func(id string) error {
+		_, err := mx.ServeP2P(context.Background(), &p2p.MessageRequest{From: &p2p.Peer{Id: id}, Subject: "echo"})
+		return err
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := send("a"); err != nil {
+			t.Fatalf("expected the burst to be allowed, got %s", err)
+		}
+	}
+
+	if err := send("a"); !errors.Is(err, p2p.ErrRateLimited) {
+		t.Errorf("expected peer a to be limited, got %v", err)
+	}
+
+	if err := send("b"); err != nil {
+		t.Errorf("expected peer b to have its own budget, got %s", err)
+	}
+}
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	mx := p2p.NewServeMux()
+	mx.Use(p2p.Logger(log.New(&buf, "", 0)))
+	mx.Handle("echo", named("echo"))
+
+	mx.ServeP2P(context.Background(), &p2p.MessageRequest{From: &p2p.Peer{Id: "peer-a"}, Subject: "echo"})
+	mx.ServeP2P(context.Background(), &p2p.MessageRequest{From: &p2p.Peer{Id: "peer-a"}, Subject: "missing"})
+
+	out := buf.String()
+	if !strings.Contains(out, "message echo from peer-a served") || !strings.Contains(out, "message missing from peer-a failed") {
+		t.Errorf("unexpected log output: %s", out)
+	}
+}
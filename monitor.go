@@ -67,20 +67,20 @@ func (m *Monitor) View() string {
 
 	wr := tabwriter.NewWriter(&sb, 6, 2, 2, ' ', tabwriter.Debug)
 
-	fmt.Fprintln(wr, "Addr\tName\tSince\tCurrent")
+	fmt.Fprintln(wr, "Addr\tName\tStatus\tSince\tCurrent")
 
 	current := m.state.Current
 
 	if current != nil {
 		t, _ := time.Parse(time.RFC3339, current.UpdatedAt)
 		since := time.Since(t).Truncate(time.Second)
-		fmt.Fprintf(wr, "%s\t%s\t%s\t%s\n", current.Addr, current.Name, since, "*")
+		fmt.Fprintf(wr, "%s\t%s\t%s\t%s\t%s\n", current.Addr, current.Name, current.Status, since, "*")
 	}
 
 	for _, peer := range m.state.Peers {
 		t, _ := time.Parse(time.RFC3339, peer.UpdatedAt)
 		since := time.Since(t).Truncate(time.Second)
-		fmt.Fprintf(wr, "%s\t%s\t%s\t%s\n", peer.Addr, peer.Name, since, "")
+		fmt.Fprintf(wr, "%s\t%s\t%s\t%s\t%s\n", peer.Addr, peer.Name, peer.Status, since, "")
 	}
 
 	wr.Flush()
@@ -5,14 +5,26 @@ import (
 	"fmt"
 
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 )
 
 func Subscribe2Grpc(ctx context.Context, addr string, out chan<- *State) error {
-	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	return (&GrpcTransport{}).Subscribe(ctx, addr, out)
+}
+
+// GrpcSubscriber returns a MonitorSubscribeFunc signing the state stream
+// with key, as servers with a key require.
+func GrpcSubscriber(key string) MonitorSubscribeFunc {
+	return (&GrpcTransport{Key: key}).Subscribe
+}
+
+// Subscribe is a MonitorSubscribeFunc streaming the states of the node at
+// addr with the transport key, over tls when TLSConfig is set.
+func (n *GrpcTransport) Subscribe(ctx context.Context, addr string, out chan<- *State) error {
+	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(n.credentials()), grpc.WithStreamInterceptor(n.signStream))
 	if err != nil {
 		return fmt.Errorf("failed at connection: %w", err)
 	}
+	defer conn.Close()
 
 	client := NewP2PClient(conn)
 	sc, err := client.State(ctx, &StateRequest{})
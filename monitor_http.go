@@ -10,19 +10,32 @@ import (
 )
 
 func Subscribe2Http(ctx context.Context, addr string, out chan<- *State) error {
-	req, err := http.NewRequestWithContext(ctx, "GET", addr+"/p2p/state", nil)
+	return (&HttpTransport{}).Subscribe(ctx, addr, out)
+}
+
+// HttpSubscriber returns a MonitorSubscribeFunc signing the state request
+// with key, as servers with a key require.
+func HttpSubscriber(key string) MonitorSubscribeFunc {
+	return (&HttpTransport{Key: key}).Subscribe
+}
+
+// Subscribe is a MonitorSubscribeFunc streaming the states of the node at
+// addr with the transport key and client, so it reaches tls servers too.
+func (n *HttpTransport) Subscribe(ctx context.Context, addr string, out chan<- *State) error {
+	client, base := n.target(addr)
+	req, err := http.NewRequestWithContext(ctx, "GET", base+"/p2p/state", nil)
 	if err != nil {
 		return fmt.Errorf("failed making state request: %w", err)
 	}
 
+	n.sign(req, nil)
 	req.Header.Set("accept", "text/event-stream")
 	req.Header.Set("connection", "keep-alive")
 	req.Header.Set("Cache-Control", "no-cache")
 
-	var h http.Client
-	res, err := h.Do(req)
+	res, err := doRequest(client, req)
 	if err != nil {
-		return fmt.Errorf("failed doing sse request: %w", err)
+		return err
 	}
 
 	defer res.Body.Close()
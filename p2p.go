@@ -5,15 +5,29 @@ import (
 	"fmt"
 	"log"
 	"net"
+	"strings"
 	"sync"
 	"time"
 
-	"github.com/google/uuid"
+	"google.golang.org/protobuf/proto"
 )
 
 type Transport interface {
-	Connect(from *Peer, addr string) (*State, error)
-	Send(from, to *Peer, subject string, body []byte) ([]byte, error)
+	Connect(ctx context.Context, from *Peer, addr string) (*State, error)
+	Send(ctx context.Context, from, to *Peer, subject string, body []byte) ([]byte, error)
+	Leave(ctx context.Context, from, to *Peer) error
+}
+
+// PeerForgetter is implemented by transports holding resources per peer,
+// released once the peer is removed.
+type PeerForgetter interface {
+	Forget(peer *Peer)
+}
+
+// PeerConnector is implemented by transports able to reconnect a known peer
+// through any of the endpoints it advertises rather than its addr alone.
+type PeerConnector interface {
+	ConnectPeer(ctx context.Context, from, to *Peer) (*State, error)
 }
 
 type Server interface {
@@ -23,39 +37,124 @@ type Server interface {
 
 type P2P struct {
 	current   *Peer
+	derived   bool
+	identity  *Identity
+	clock     Clock
 	lookup    []string
 	peers     map[string]*Peer
 	channel   chan *State
 	mutex     sync.RWMutex
 	handler   Handler
 	transport Transport
+	swim      *swim
+	detector  FailureDetector
+	balancer  Balancer
+	evict     time.Duration
+	interval  time.Duration
+	timeout   time.Duration
+	died      map[string]time.Time
+	evicted   map[string]time.Time
+	cancel    context.CancelFunc
+	closed    bool
+	done      chan struct{}
+	pending   sync.WaitGroup
 }
 
 type Options struct {
 	Name      string
 	Addr      string
+	Labels    map[string]string
+	Protocols []string
+	Endpoints []*Endpoint
+	Identity  *Identity
 	Lookup    []string
 	Transport Transport
+	Swim      *SwimOptions
+	Detector  FailureDetector
+	Balancer  Balancer
+	Evict     time.Duration
+	Interval  time.Duration
+	Timeout   time.Duration
+	Clock     Clock
 }
 
+// New panics when no Identity is given and generating one fails, as the
+// system random source is broken then.
 func New(opts Options) *P2P {
+	clock := opts.Clock
+	if clock == nil {
+		clock = systemClock{}
+	}
+
+	identity := opts.Identity
+	if identity == nil {
+		var err error
+		identity, err = GenerateIdentity()
+		if err != nil {
+			panic(err)
+		}
+	}
 
 	current := &Peer{
-		Id:          uuid.New().String(),
+		Id:          identity.Id(),
+		PublicKey:   identity.PublicKey(),
 		Name:        opts.Name,
-		CreatedAt:   time.Now().Format(time.RFC3339),
-		UpdatedAt:   time.Now().Format(time.RFC3339),
+		CreatedAt:   clock.Now().Format(time.RFC3339),
+		UpdatedAt:   clock.Now().Format(time.RFC3339),
 		Addr:        opts.Addr,
-		RefreshedAt: time.Now().Format(time.RFC3339),
+		RefreshedAt: clock.Now().Format(time.RFC3339),
+		Labels:      opts.Labels,
+		Protocols:   opts.Protocols,
+		Endpoints:   opts.Endpoints,
+	}
+
+	derived := len(opts.Endpoints) == 0
+	if derived {
+		current.Endpoints = endpointsOf(opts.Addr, opts.Protocols)
 	}
 
 	p := &P2P{
 		current:   current,
+		derived:   derived,
+		identity:  identity,
+		clock:     clock,
 		lookup:    opts.Lookup,
 		peers:     make(map[string]*Peer),
 		channel:   make(chan *State),
+		done:      make(chan struct{}),
 		handler:   NewServeMux(),
 		transport: opts.Transport,
+		detector:  opts.Detector,
+		balancer:  opts.Balancer,
+		evict:     opts.Evict,
+		interval:  opts.Interval,
+		timeout:   opts.Timeout,
+		died:      make(map[string]time.Time),
+		evicted:   make(map[string]time.Time),
+	}
+
+	if p.detector == nil {
+		p.detector = NewPhiAccrualDetector()
+	}
+
+	if p.balancer == nil {
+		p.balancer = &RandomBalancer{}
+	}
+
+	if p.evict <= 0 {
+		p.evict = 30 * time.Second
+	}
+
+	if p.interval <= 0 {
+		p.interval = 5 * time.Second
+	}
+
+	if p.timeout <= 0 {
+		p.timeout = 30 * time.Second
+	}
+
+	if opts.Swim != nil {
+		p.swim = newSwim(p, *opts.Swim)
 	}
 
 	return p
@@ -67,14 +166,29 @@ func (p *P2P) CurrentAddr() string {
 
 func (p *P2P) SetCurrentAddr(addr string) {
 	p.current.Addr = addr
-	p.current.UpdatedAt = time.Now().Format(time.RFC3339)
-	p.current.RefreshedAt = time.Now().Format(time.RFC3339)
+	if p.derived {
+		p.current.Endpoints = endpointsOf(addr, p.current.Protocols)
+	}
+	p.current.UpdatedAt = p.clock.Now().Format(time.RFC3339)
+	p.current.RefreshedAt = p.clock.Now().Format(time.RFC3339)
+}
+
+// SetEndpoints replaces the endpoints the current peer advertises, which are
+// otherwise derived from its addr and protocols.
+func (p *P2P) SetEndpoints(endpoints []*Endpoint) {
+	p.derived = false
+	p.current.Endpoints = endpoints
+	p.current.UpdatedAt = p.clock.Now().Format(time.RFC3339)
 }
 
 func (p *P2P) SetTransport(n Transport) {
 	p.transport = n
 }
 
+func (p *P2P) SetBalancer(b Balancer) {
+	p.balancer = b
+}
+
 func (p *P2P) Handle(h Handler) {
 	p.handler = h
 }
@@ -83,6 +197,19 @@ func (p *P2P) HandleFunc(f func(ctx context.Context, r *MessageRequest) ([]byte,
 	p.Handle(HandlerFunc(f))
 }
 
+func (p *P2P) ServeP2P(ctx context.Context, r *MessageRequest) ([]byte, error) {
+	err := p.verify(r.From, proofMessage, r.Subject, r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.swim != nil && isSwimSubject(r.Subject) {
+		return p.swim.ServeP2P(ctx, r)
+	}
+
+	return p.handler.ServeP2P(ctx, r)
+}
+
 func (p *P2P) Channel() <-chan *State {
 	return p.channel
 }
@@ -98,25 +225,136 @@ func (p *P2P) Peers() []*Peer {
 }
 
 func (p *P2P) State() *State {
-	return &State{Current: p.current, Peers: p.Peers()}
+	return &State{Current: p.self(), Peers: p.Peers()}
 }
 
-func (p *P2P) Start() {
+// self returns the current peer along the swim incarnation it is at.
+func (p *P2P) self() *Peer {
+	if p.swim == nil {
+		return p.current
+	}
+
+	current := proto.Clone(p.current).(*Peer)
+	current.Incarnation = p.swim.self()
+	return current
+}
+
+func (p *P2P) Start(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	p.mutex.Lock()
+	if p.closed {
+		p.mutex.Unlock()
+		return
+	}
+	p.cancel = cancel
+	p.mutex.Unlock()
+
+	interval := p.interval
+	if p.swim != nil {
+		interval = p.swim.opts.Interval
+	}
+
+	ticker := p.clock.NewTicker(interval)
+	defer ticker.Stop()
+
 	for {
-		p.scan()
-		time.Sleep(5 * time.Second)
+		p.Scan(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.done:
+			return
+		case <-ticker.C():
+		}
+	}
+}
+
+func (p *P2P) Close() error {
+	p.mutex.Lock()
+	if p.closed {
+		p.mutex.Unlock()
+		return nil
+	}
+	p.closed = true
+	if p.cancel != nil {
+		p.cancel()
+	}
+	close(p.done)
+	p.mutex.Unlock()
+
+	// leaves go out in parallel under one deadline, so closing takes at most
+	// an interval however many peers are unreachable.
+	ctx, cancel := context.WithTimeout(context.Background(), p.interval)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, peer := range p.Peers() {
+		peer := peer
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := p.transport.Leave(ctx, p.sign(proofLeave, peer.Id, "", nil), peer)
+			if err != nil {
+				log.Println("failed leave notice", peer.Addr, err)
+			}
+		}()
 	}
+	wg.Wait()
+
+	p.pending.Wait()
+	close(p.channel)
+	return nil
 }
 
 func (p *P2P) Save(peer *Peer) {
-	p.register(peer)
+	p.register(peer, true)
+	p.heartbeat(peer.Id)
 	p.notify()
 }
 
-func (p *P2P) scan() {
-	if len(p.peers) == 0 && len(p.lookup) > 0 {
+// Accept registers a peer connecting to this node once its proof is verified,
+// returning the state to reply with.
+func (p *P2P) Accept(from *Peer) (*State, error) {
+	err := p.verify(from, proofConnect, "", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	p.Save(from)
+	return p.State(), nil
+}
+
+// Depart removes a peer leaving this node once its proof is verified.
+func (p *P2P) Depart(from *Peer) error {
+	err := p.verify(from, proofLeave, "", nil)
+	if err != nil {
+		return err
+	}
+
+	p.Remove(from)
+	return nil
+}
+
+func (p *P2P) Remove(peer *Peer) {
+	if p.swim != nil {
+		p.swim.leave(peer)
+	}
+
+	old := p.unregister(peer.Id)
+	if old != nil {
+		p.forget(old)
+		log.Println("client left", peer.Addr)
+		p.notify()
+	}
+}
+
+// Scan runs a single discovery round, as Start does every interval.
+func (p *P2P) Scan(ctx context.Context) {
+	if len(p.Peers()) == 0 && len(p.lookup) > 0 {
 		for _, addr := range p.lookup {
-			err := p.Discover(addr)
+			err := p.discover(ctx, addr)
 			if err != nil {
 				log.Printf("failed lookup %s\n", err)
 				continue
@@ -126,47 +364,251 @@ func (p *P2P) scan() {
 		}
 	}
 
-	for id, client := range p.peers {
-		err := p.Discover(client.Addr)
+	if p.swim != nil {
+		p.swim.probe(ctx)
+		p.expire()
+		return
+	}
+
+	for _, client := range p.Peers() {
+		err := p.reconnect(ctx, client)
 		if err != nil {
-			p.mutex.Lock()
-			delete(p.peers, id)
-			p.mutex.Unlock()
-			log.Println("client disconnected", client.Addr, err)
-			continue
+			p.detector.Miss(client.Id, p.clock.Now())
+			log.Println("client unreachable", client.Addr, err)
+		} else {
+			p.heartbeat(client.Id)
 		}
+
+		p.setStatus(client.Id, p.detector.Status(client.Id, p.clock.Now()))
 	}
 
+	p.expire()
 	p.notify()
 }
 
-func (p *P2P) register(peer *Peer) {
+func (p *P2P) heartbeat(id string) {
+	p.detector.Heartbeat(id, p.clock.Now())
+}
+
+func (p *P2P) setStatus(id string, status PeerStatus) {
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
 
-	if p.current.Id == peer.Id {
+	peer, ok := p.peers[id]
+	if !ok || peer.Status == status {
+		return
+	}
+
+	log.Println("client", strings.ToLower(status.String()), peer.Addr)
+	if status == PeerStatus_DEAD {
+		p.died[id] = p.clock.Now()
+	} else {
+		delete(p.died, id)
+	}
+
+	peer = proto.Clone(peer).(*Peer)
+	peer.Status = status
+	p.peers[id] = peer
+}
+
+// expire evicts peers dead for longer than evict, keeping them tombstoned for
+// as long again so the gossip of nodes yet to evict them doesn't bring them back.
+func (p *P2P) expire() {
+	var evicted []*Peer
+	now := p.clock.Now()
+
+	p.mutex.Lock()
+	for id, peer := range p.peers {
+		if peer.Status == PeerStatus_DEAD && now.Sub(p.died[id]) > p.evict {
+			delete(p.peers, id)
+			delete(p.died, id)
+			p.evicted[id] = now
+			evicted = append(evicted, peer)
+		}
+	}
+
+	for id, at := range p.evicted {
+		if now.Sub(at) > p.evict {
+			delete(p.evicted, id)
+		}
+	}
+	p.mutex.Unlock()
+
+	for _, peer := range evicted {
+		p.detector.Forget(peer.Id)
+		p.forget(peer)
+		log.Println("client disconnected", peer.Addr)
+	}
+
+	if len(evicted) > 0 {
+		p.notify()
+	}
+}
+
+// register stores a peer, direct tells whether it was heard from the peer
+// itself rather than gossiped by another node.
+func (p *P2P) register(peer *Peer, direct bool) {
+	if !p.store(peer, direct) {
 		return
 	}
 
-	peer.RefreshedAt = time.Now().Format(time.RFC3339)
+	if p.swim != nil {
+		p.swim.join(peer)
+	}
+}
+
+func (p *P2P) store(peer *Peer, direct bool) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.current.Id == peer.Id || !validPeerId(peer) {
+		return false
+	}
+
+	_, evicted := p.evicted[peer.Id]
+	if evicted && !direct {
+		return false
+	}
+	delete(p.evicted, peer.Id)
+
+	old, ok := p.peers[peer.Id]
+	if ok {
+		peer.Status = old.Status
+	} else {
+		// seed the detector history, so a peer only ever heard of through
+		// gossip still turns suspect once it stays unreachable
+		peer.Status = PeerStatus_ALIVE
+		p.detector.Heartbeat(peer.Id, p.clock.Now())
+	}
+
+	peer.Proof = nil
+	peer.RefreshedAt = p.clock.Now().Format(time.RFC3339)
 	p.peers[peer.Id] = peer
+	return true
+}
+
+func (p *P2P) unregister(id string) *Peer {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	peer := p.peers[id]
+	delete(p.peers, id)
+	delete(p.died, id)
+	p.detector.Forget(id)
+	return peer
+}
+
+// forget releases what the transport holds for a removed peer.
+func (p *P2P) forget(peer *Peer) {
+	f, ok := p.transport.(PeerForgetter)
+	if ok {
+		f.Forget(peer)
+	}
 }
 
 func (p *P2P) Discover(target string) error {
-	state, err := p.transport.Connect(p.current, target)
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+	return p.DiscoverContext(ctx, target)
+}
+
+func (p *P2P) DiscoverContext(ctx context.Context, target string) error {
+	state, err := p.transport.Connect(ctx, p.sign(proofConnect, proofTarget(target), "", nil), target)
+	if err != nil {
+		return fmt.Errorf("failed at transport connect: %w", err)
+	}
+
+	p.register(state.Current, true)
+	for _, peer := range state.Peers {
+		p.register(peer, false)
+	}
+	return nil
+}
+
+func (p *P2P) discover(ctx context.Context, target string) error {
+	ctx, cancel := context.WithTimeout(ctx, p.interval)
+	defer cancel()
+	return p.DiscoverContext(ctx, target)
+}
+
+// reconnect refreshes the state of a known peer, through any of its endpoints
+// when the transport supports it.
+func (p *P2P) reconnect(ctx context.Context, peer *Peer) error {
+	connector, ok := p.transport.(PeerConnector)
+	if !ok {
+		return p.discover(ctx, peer.Addr)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.interval)
+	defer cancel()
+
+	state, err := connector.ConnectPeer(ctx, p.sign(proofConnect, proofTarget(peer.Addr), "", nil), peer)
 	if err != nil {
 		return fmt.Errorf("failed at transport connect: %w", err)
 	}
 
-	peers := append(state.Peers, state.Current)
-	for _, peer := range peers {
-		p.register(peer)
+	p.register(state.Current, true)
+	for _, peer := range state.Peers {
+		p.register(peer, false)
 	}
 	return nil
 }
 
+// sign returns the current peer with a proof of the action addressed to target.
+func (p *P2P) sign(action, target, subject string, body []byte) *Peer {
+	return p.identity.proof(p.self(), p.clock.Now(), action, target, subject, body)
+}
+
+func (p *P2P) verify(from *Peer, action, subject string, body []byte) error {
+	if action != proofConnect {
+		return verifyProof(from, p.clock.Now(), action, p.current.Id, subject, body, defaultSkew)
+	}
+
+	// connect proofs are addressed to whichever address of this node the
+	// peer dialed, so a captured one can't be replayed to other nodes
+	err := fmt.Errorf("%w: no address to connect to", ErrInvalidProof)
+	for _, target := range p.targets() {
+		err = verifyProof(from, p.clock.Now(), action, target, subject, body, defaultSkew)
+		if err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// targets returns the proof targets of the addresses this node is reached at.
+func (p *P2P) targets() []string {
+	var targets []string
+	seen := make(map[string]bool)
+	add := func(addr string) {
+		target := proofTarget(addr)
+		if addr != "" && !seen[target] {
+			seen[target] = true
+			targets = append(targets, target)
+		}
+	}
+
+	add(p.current.Addr)
+	for _, e := range p.current.Endpoints {
+		add(e.Address())
+	}
+	return targets
+}
+
 func (p *P2P) notify() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.closed {
+		return
+	}
+
+	p.pending.Add(1)
 	go func() {
-		p.channel <- p.State()
+		defer p.pending.Done()
+		select {
+		case p.channel <- p.State():
+		case <-p.done:
+		}
 	}()
 }
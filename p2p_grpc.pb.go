@@ -21,6 +21,7 @@ type P2PClient interface {
 	State(ctx context.Context, in *StateRequest, opts ...grpc.CallOption) (P2P_StateClient, error)
 	Connect(ctx context.Context, in *ConnectRequest, opts ...grpc.CallOption) (*ConnectResponse, error)
 	Message(ctx context.Context, in *MessageRequest, opts ...grpc.CallOption) (*MessageResponse, error)
+	Leave(ctx context.Context, in *LeaveRequest, opts ...grpc.CallOption) (*LeaveResponse, error)
 }
 
 type p2PClient struct {
@@ -81,6 +82,15 @@ func (c *p2PClient) Message(ctx context.Context, in *MessageRequest, opts ...grp
 	return out, nil
 }
 
+func (c *p2PClient) Leave(ctx context.Context, in *LeaveRequest, opts ...grpc.CallOption) (*LeaveResponse, error) {
+	out := new(LeaveResponse)
+	err := c.cc.Invoke(ctx, "/github.com.yaien.p2p.P2P/Leave", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // P2PServer is the server API for P2P service.
 // All implementations must embed UnimplementedP2PServer
 // for forward compatibility
@@ -88,6 +98,7 @@ type P2PServer interface {
 	State(*StateRequest, P2P_StateServer) error
 	Connect(context.Context, *ConnectRequest) (*ConnectResponse, error)
 	Message(context.Context, *MessageRequest) (*MessageResponse, error)
+	Leave(context.Context, *LeaveRequest) (*LeaveResponse, error)
 	mustEmbedUnimplementedP2PServer()
 }
 
@@ -104,6 +115,9 @@ func (UnimplementedP2PServer) Connect(context.Context, *ConnectRequest) (*Connec
 func (UnimplementedP2PServer) Message(context.Context, *MessageRequest) (*MessageResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Message not implemented")
 }
+func (UnimplementedP2PServer) Leave(context.Context, *LeaveRequest) (*LeaveResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Leave not implemented")
+}
 func (UnimplementedP2PServer) mustEmbedUnimplementedP2PServer() {}
 
 // UnsafeP2PServer may be embedded to opt out of forward compatibility for this service.
@@ -174,6 +188,24 @@ func _P2P_Message_Handler(srv interface{}, ctx context.Context, dec func(interfa
 	return interceptor(ctx, in, info, handler)
 }
 
+func _P2P_Leave_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LeaveRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(P2PServer).Leave(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/github.com.yaien.p2p.P2P/Leave",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(P2PServer).Leave(ctx, req.(*LeaveRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // P2P_ServiceDesc is the grpc.ServiceDesc for P2P service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -189,6 +221,10 @@ var P2P_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Message",
 			Handler:    _P2P_Message_Handler,
 		},
+		{
+			MethodName: "Leave",
+			Handler:    _P2P_Leave_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -0,0 +1,95 @@
+package p2p_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/yaien/p2p"
+	"golang.org/x/net/nettest"
+)
+
+func TestP2P_Start_Cancel(t *testing.T) {
+	p := p2p.New(p2p.Options{Transport: &p2p.HttpTransport{}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		p.Start(ctx)
+		close(done)
+	}()
+
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("start did not return after context cancellation")
+	}
+}
+
+func TestP2P_Http_Close(t *testing.T) {
+	transport := &p2p.HttpTransport{}
+	from := p2p.New(p2p.Options{Name: "from-p2p", Transport: transport})
+
+	mx := http.NewServeMux()
+	srv := httptest.NewServer(mx)
+	defer srv.Close()
+
+	to := p2p.New(p2p.Options{Addr: srv.URL, Name: "target-p2p", Transport: transport})
+	p2p.NewHttpServer(to, nil, "").Register(mx)
+
+	err := from.Discover(to.CurrentAddr())
+	if err != nil {
+		t.Fatalf("failed at discover: %s", err)
+	}
+
+	if len(to.Peers()) != 1 {
+		t.Fatalf("expected target to know 1 peer, got %d", len(to.Peers()))
+	}
+
+	err = from.Close()
+	if err != nil {
+		t.Fatalf("failed at close: %s", err)
+	}
+
+	if len(to.Peers()) != 0 {
+		t.Errorf("expected target to drop the leaving peer, got %d peers", len(to.Peers()))
+	}
+
+	if _, ok := <-from.Channel(); ok {
+		t.Error("expected state channel to be closed")
+	}
+}
+
+func TestP2P_Grpc_Close(t *testing.T) {
+	transport := &p2p.GrpcTransport{}
+	from := p2p.New(p2p.Options{Name: "from-p2p", Transport: transport})
+
+	lis, err := nettest.NewLocalListener("tcp")
+	if err != nil {
+		t.Fatalf("failed creating testing listener: %s", err)
+	}
+
+	to := p2p.New(p2p.Options{Addr: lis.Addr().String(), Name: "target-p2p", Transport: transport})
+
+	srv := p2p.NewGrpcServer(to, nil, "")
+	go srv.Serve(lis)
+	defer srv.Close()
+
+	err = from.Discover(to.CurrentAddr())
+	if err != nil {
+		t.Fatalf("failed at discover: %s", err)
+	}
+
+	err = from.Close()
+	if err != nil {
+		t.Fatalf("failed at close: %s", err)
+	}
+
+	if len(to.Peers()) != 0 {
+		t.Errorf("expected target to drop the leaving peer, got %d peers", len(to.Peers()))
+	}
+}
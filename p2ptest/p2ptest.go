@@ -0,0 +1,310 @@
+// Package p2ptest verifies that a Transport and its server behave the way the
+// p2p package expects from any of them.
+package p2ptest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yaien/p2p"
+)
+
+// Factory builds the pieces of a transport under test.
+type Factory struct {
+	// NewTransport returns a client transport authenticating with key.
+	NewTransport func(key string) p2p.Transport
+
+	// Serve serves p until the test ends, only accepting clients using key. It
+	// must set the current addr of p and return it.
+	Serve func(tb testing.TB, p *p2p.P2P, key string) string
+}
+
+const key = "conformance"
+
+// TestTransport runs the conformance suite against the transport built by f.
+func TestTransport(t *testing.T, f Factory) {
+	t.Run("Join", func(t *testing.T) { testJoin(t, f) })
+	t.Run("RoundTrip", func(t *testing.T) { testRoundTrip(t, f) })
+	t.Run("SendWithoutConnect", func(t *testing.T) { testSendWithoutConnect(t, f) })
+	t.Run("ErrorPropagation", func(t *testing.T) { testErrorPropagation(t, f) })
+	t.Run("Headers", func(t *testing.T) { testHeaders(t, f) })
+	t.Run("ContentType", func(t *testing.T) { testContentType(t, f) })
+	t.Run("Deadline", func(t *testing.T) { testDeadline(t, f) })
+	t.Run("LargeBody", func(t *testing.T) { testLargeBody(t, f) })
+	t.Run("BinaryBody", func(t *testing.T) { testBinaryBody(t, f) })
+	t.Run("ConcurrentSends", func(t *testing.T) { testConcurrentSends(t, f) })
+	t.Run("AuthRejection", func(t *testing.T) { testAuthRejection(t, f) })
+	t.Run("Leave", func(t *testing.T) { testLeave(t, f) })
+}
+
+func node(f Factory, name string, key string) *p2p.P2P {
+	return p2p.New(p2p.Options{Name: name, Transport: f.NewTransport(key), Timeout: 10 * time.Second})
+}
+
+// pair serves a target echoing every message and joins a client to it.
+func pair(t *testing.T, f Factory) (from, to *p2p.P2P) {
+	to = node(f, "target", key)
+	to.HandleFunc(func(ctx context.Context, r *p2p.MessageRequest) ([]byte, error) {
+		return r.Body, nil
+	})
+	f.Serve(t, to, key)
+
+	from = node(f, "client", key)
+	err := from.Discover(to.CurrentAddr())
+	if err != nil {
+		t.Fatalf("failed at discover: %s", err)
+	}
+
+	return from, to
+}
+
+func known(p *p2p.P2P, id string) bool {
+	for _, peer := range p.Peers() {
+		if peer.Id == id {
+			return true
+		}
+	}
+	return false
+}
+
+func testJoin(t *testing.T, f Factory) {
+	from, to := pair(t, f)
+
+	if !known(from, to.State().Current.Id) {
+		t.Error("expected client to know the target after discover")
+	}
+
+	if !known(to, from.State().Current.Id) {
+		t.Error("expected target to know the client after discover")
+	}
+}
+
+func testRoundTrip(t *testing.T, f Factory) {
+	from, _ := pair(t, f)
+
+	body := []byte(`{"message":"hello"}`)
+	reply, err := from.Request("target", "echo", body)
+	if err != nil {
+		t.Fatalf("failed at request: %s", err)
+	}
+
+	if !bytes.Equal(reply, body) {
+		t.Errorf("expected reply %s, got %s", body, reply)
+	}
+}
+
+func testSendWithoutConnect(t *testing.T, f Factory) {
+	_, to := pair(t, f)
+
+	from := node(f, "client", key)
+	from.Save(to.State().Current)
+
+	body := []byte(`{"message":"hello"}`)
+	reply, err := from.Request("target", "echo", body)
+	if err != nil {
+		t.Fatalf("failed at request to a peer never connected to: %s", err)
+	}
+
+	if !bytes.Equal(reply, body) {
+		t.Errorf("expected reply %s, got %s", body, reply)
+	}
+}
+
+func testErrorPropagation(t *testing.T, f Factory) {
+	from, to := pair(t, f)
+
+	to.HandleFunc(func(ctx context.Context, r *p2p.MessageRequest) ([]byte, error) {
+		return nil, errors.New("handler exploded")
+	})
+
+	_, err := from.Request("target", "fail", []byte(`{}`))
+	if err == nil {
+		t.Fatal("expected the handler error to reach the caller")
+	}
+
+	if !strings.Contains(err.Error(), "handler exploded") {
+		t.Errorf("expected the handler message in %q", err)
+	}
+
+	to.HandleFunc(func(ctx context.Context, r *p2p.MessageRequest) ([]byte, error) {
+		return nil, &p2p.Error{Code: p2p.CodeNotFound, Message: "order 42 not found", Details: map[string]string{"id": "42"}}
+	})
+
+	_, err = from.Request("target", "fail", []byte(`{}`))
+	var e *p2p.Error
+	if !errors.As(err, &e) {
+		t.Fatalf("expected a *p2p.Error, got %T %v", err, err)
+	}
+
+	if e.Code != p2p.CodeNotFound || e.Message != "order 42 not found" || e.Details["id"] != "42" {
+		t.Errorf("expected the handler error code, message and details, got %+v", e)
+	}
+}
+
+func testHeaders(t *testing.T, f Factory) {
+	from, to := pair(t, f)
+
+	to.HandleFunc(func(ctx context.Context, r *p2p.MessageRequest) ([]byte, error) {
+		p2p.SetReplyHeader(ctx, "trace-id", r.Headers["trace-id"])
+		return r.Body, nil
+	})
+
+	opts := p2p.MessageOptions{Headers: map[string]string{"trace-id": "abc"}}
+	reply, err := from.RequestMessage(context.Background(), "target", "echo", []byte(`{}`), opts)
+	if err != nil {
+		t.Fatalf("failed at request: %s", err)
+	}
+
+	if reply.Headers["trace-id"] != "abc" {
+		t.Errorf("expected the request header to come back as a reply header, got %v", reply.Headers)
+	}
+}
+
+func testContentType(t *testing.T, f Factory) {
+	from, to := pair(t, f)
+
+	to.HandleFunc(func(ctx context.Context, r *p2p.MessageRequest) ([]byte, error) {
+		return []byte(r.ContentType), nil
+	})
+
+	opts := p2p.MessageOptions{ContentType: "application/msgpack"}
+	reply, err := from.RequestMessage(context.Background(), "target", "content-type", []byte{0x81, 0xa1, 0x61, 0x01}, opts)
+	if err != nil {
+		t.Fatalf("failed at request: %s", err)
+	}
+
+	if string(reply.Body) != opts.ContentType {
+		t.Errorf("expected the handler to see content type %s, got %q", opts.ContentType, reply.Body)
+	}
+}
+
+func testDeadline(t *testing.T, f Factory) {
+	from, to := pair(t, f)
+
+	left := make(chan time.Duration, 1)
+	to.HandleFunc(func(ctx context.Context, r *p2p.MessageRequest) ([]byte, error) {
+		deadline, ok := ctx.Deadline()
+		if !ok {
+			left <- 0
+		} else {
+			left <- time.Until(deadline)
+		}
+		return r.Body, nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	_, err := from.RequestContext(ctx, "target", "deadline", []byte(`{}`))
+	if err != nil {
+		t.Fatalf("failed at request: %s", err)
+	}
+
+	d := <-left
+	if d <= 0 || d > 2*time.Second {
+		t.Errorf("expected the handler to run within the 2s caller deadline, got %s left", d)
+	}
+}
+
+func testLargeBody(t *testing.T, f Factory) {
+	from, _ := pair(t, f)
+
+	body := []byte(`"` + strings.Repeat("p2p", 1<<18) + `"`)
+	reply, err := from.Request("target", "echo", body)
+	if err != nil {
+		t.Fatalf("failed at request: %s", err)
+	}
+
+	if !bytes.Equal(reply, body) {
+		t.Errorf("expected a %d bytes reply, got %d bytes", len(body), len(reply))
+	}
+}
+
+func testBinaryBody(t *testing.T, f Factory) {
+	from, _ := pair(t, f)
+
+	body := make([]byte, 4096)
+	for i := range body {
+		body[i] = byte(i * 7)
+	}
+
+	bodies := [][]byte{
+		body,
+		{0x1f, 0x8b, 0x08, 0x00},
+		[]byte("plain text"),
+		[]byte("{\"a\": 1}\n"),
+		[]byte(`{"a":"<b>"}`),
+	}
+
+	for _, body := range bodies {
+		reply, err := from.Request("target", "echo", body)
+		if err != nil {
+			t.Fatalf("failed at request: %s", err)
+		}
+
+		if !bytes.Equal(reply, body) {
+			t.Errorf("expected the %d bytes body back, got %d bytes", len(body), len(reply))
+		}
+	}
+}
+
+func testConcurrentSends(t *testing.T, f Factory) {
+	from, _ := pair(t, f)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			body := []byte(fmt.Sprintf(`{"n":%d}`, i))
+			reply, err := from.Request("target", "echo", body)
+			if err != nil {
+				t.Errorf("failed at request %d: %s", i, err)
+				return
+			}
+			if !bytes.Equal(reply, body) {
+				t.Errorf("expected reply %s, got %s", body, reply)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func testAuthRejection(t *testing.T, f Factory) {
+	_, to := pair(t, f)
+
+	intruder := node(f, "intruder", "wrong")
+	err := intruder.Discover(to.CurrentAddr())
+	if err == nil {
+		t.Error("expected discover with a wrong key to fail")
+	}
+
+	intruder.Save(to.State().Current)
+	_, err = intruder.Request("target", "echo", []byte(`{}`))
+	if err == nil {
+		t.Error("expected request with a wrong key to fail")
+	}
+
+	if known(to, intruder.State().Current.Id) {
+		t.Error("expected target to ignore the intruder")
+	}
+}
+
+func testLeave(t *testing.T, f Factory) {
+	from, to := pair(t, f)
+
+	err := from.Close()
+	if err != nil {
+		t.Fatalf("failed at close: %s", err)
+	}
+
+	if known(to, from.State().Current.Id) {
+		t.Error("expected target to drop the leaving peer")
+	}
+}
@@ -0,0 +1,148 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrQuorumNotReached = errors.New("quorum not reached")
+	ErrPeerTimeout      = errors.New("peer request timed out")
+)
+
+type Reply struct {
+	Peer    *Peer
+	Body    []byte
+	Err     error
+	Latency time.Duration
+}
+
+// Replies holds the outcome of a RequestAll keyed by peer id. Peers that had
+// not answered when the call returned are absent.
+type Replies map[string]*Reply
+
+func (r Replies) Successes() int {
+	var n int
+	for _, reply := range r {
+		if reply.Err == nil {
+			n++
+		}
+	}
+	return n
+}
+
+func (r Replies) Errors() map[string]error {
+	errs := make(map[string]error)
+	for id, reply := range r {
+		if reply.Err != nil {
+			errs[id] = reply.Err
+		}
+	}
+	return errs
+}
+
+type requestAllOptions struct {
+	quorum  int
+	timeout time.Duration
+	workers int
+}
+
+type RequestAllOption func(o *requestAllOptions)
+
+// WithQuorum makes RequestAll return as soon as n peers replied successfully.
+func WithQuorum(n int) RequestAllOption {
+	return func(o *requestAllOptions) { o.quorum = n }
+}
+
+// WithPeerTimeout bounds the time spent waiting for each single peer.
+func WithPeerTimeout(d time.Duration) RequestAllOption {
+	return func(o *requestAllOptions) { o.timeout = d }
+}
+
+// WithWorkers bounds how many peers are requested at the same time.
+func WithWorkers(n int) RequestAllOption {
+	return func(o *requestAllOptions) { o.workers = n }
+}
+
+func (p *P2P) RequestAll(ctx context.Context, pattern string, subject string, body []byte, opts ...RequestAllOption) (Replies, error) {
+	o := requestAllOptions{workers: 16}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	peers, err := p.match(globMatcher(pattern))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(peers) == 0 {
+		return nil, fmt.Errorf("%w %s", ErrNoneMatchedPeers, pattern)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan *Peer)
+	results := make(chan *Reply, len(peers))
+
+	go func() {
+		defer close(jobs)
+		for _, peer := range peers {
+			select {
+			case jobs <- peer:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	workers := o.workers
+	if workers <= 0 || workers > len(peers) {
+		workers = len(peers)
+	}
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			for peer := range jobs {
+				results <- p.requestPeer(ctx, peer, subject, body, o.timeout)
+			}
+		}()
+	}
+
+	replies := make(Replies, len(peers))
+	for len(replies) < len(peers) {
+		select {
+		case reply := <-results:
+			replies[reply.Peer.Id] = reply
+			if o.quorum > 0 && replies.Successes() >= o.quorum {
+				return replies, nil
+			}
+		case <-ctx.Done():
+			return replies, ctx.Err()
+		}
+	}
+
+	if o.quorum > 0 {
+		return replies, fmt.Errorf("%w: %d of %d", ErrQuorumNotReached, replies.Successes(), o.quorum)
+	}
+
+	return replies, nil
+}
+
+func (p *P2P) requestPeer(ctx context.Context, peer *Peer, subject string, body []byte, timeout time.Duration) *Reply {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	start := p.clock.Now()
+	reply, err := p.transport.Send(ctx, p.sign(proofMessage, peer.Id, subject, body), peer, subject, body)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("%w: %s", ErrPeerTimeout, err)
+	}
+
+	return &Reply{Peer: peer, Body: reply, Err: err, Latency: p.clock.Now().Sub(start)}
+}
@@ -0,0 +1,203 @@
+package p2p
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Selector matches peers by their labels, using the kubernetes label selector
+// syntax: "role=worker,region!=eu,tier in (a,b),!canary".
+type Selector []Requirement
+
+type Operator string
+
+const (
+	OpEquals       Operator = "="
+	OpNotEquals    Operator = "!="
+	OpIn           Operator = "in"
+	OpNotIn        Operator = "notin"
+	OpExists       Operator = "exists"
+	OpDoesNotExist Operator = "!"
+)
+
+type Requirement struct {
+	Key      string
+	Operator Operator
+	Values   []string
+}
+
+func ParseSelector(s string) (Selector, error) {
+	var selector Selector
+	for _, part := range splitSelector(s) {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		r, err := parseRequirement(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid requirement '%s': %w", part, err)
+		}
+
+		selector = append(selector, r)
+	}
+
+	return selector, nil
+}
+
+func (s Selector) Matches(labels map[string]string) bool {
+	for _, r := range s {
+		if !r.Matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s Selector) String() string {
+	parts := make([]string, 0, len(s))
+	for _, r := range s {
+		parts = append(parts, r.String())
+	}
+	return strings.Join(parts, ",")
+}
+
+func (r Requirement) Matches(labels map[string]string) bool {
+	value, ok := labels[r.Key]
+	switch r.Operator {
+	case OpEquals:
+		return ok && value == r.Values[0]
+	case OpNotEquals:
+		return !ok || value != r.Values[0]
+	case OpIn:
+		return ok && contains(r.Values, value)
+	case OpNotIn:
+		return !ok || !contains(r.Values, value)
+	case OpExists:
+		return ok
+	case OpDoesNotExist:
+		return !ok
+	default:
+		return false
+	}
+}
+
+func (r Requirement) String() string {
+	switch r.Operator {
+	case OpExists:
+		return r.Key
+	case OpDoesNotExist:
+		return "!" + r.Key
+	case OpIn, OpNotIn:
+		values := append([]string(nil), r.Values...)
+		sort.Strings(values)
+		return fmt.Sprintf("%s %s (%s)", r.Key, r.Operator, strings.Join(values, ","))
+	default:
+		return r.Key + string(r.Operator) + r.Values[0]
+	}
+}
+
+func parseRequirement(s string) (Requirement, error) {
+	if strings.HasPrefix(s, "!") {
+		key := strings.TrimSpace(s[1:])
+		if !validLabel(key) {
+			return Requirement{}, fmt.Errorf("invalid key '%s'", key)
+		}
+		return Requirement{Key: key, Operator: OpDoesNotExist}, nil
+	}
+
+	for _, op := range []string{"!=", "==", "="} {
+		i := strings.Index(s, op)
+		if i < 0 {
+			continue
+		}
+
+		key, value := strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+len(op):])
+		if !validLabel(key) {
+			return Requirement{}, fmt.Errorf("invalid key '%s'", key)
+		}
+		if value != "" && !validLabel(value) {
+			return Requirement{}, fmt.Errorf("invalid value '%s'", value)
+		}
+
+		operator := OpEquals
+		if op == "!=" {
+			operator = OpNotEquals
+		}
+		return Requirement{Key: key, Operator: operator, Values: []string{value}}, nil
+	}
+
+	fields := strings.Fields(s)
+	if len(fields) == 1 {
+		if !validLabel(fields[0]) {
+			return Requirement{}, fmt.Errorf("invalid key '%s'", fields[0])
+		}
+		return Requirement{Key: fields[0], Operator: OpExists}, nil
+	}
+
+	if len(fields) < 3 {
+		return Requirement{}, fmt.Errorf("missing operator")
+	}
+
+	key, operator := fields[0], Operator(fields[1])
+	if operator != OpIn && operator != OpNotIn {
+		return Requirement{}, fmt.Errorf("unknown operator '%s'", operator)
+	}
+
+	set := strings.TrimSpace(strings.Join(fields[2:], " "))
+	if !strings.HasPrefix(set, "(") || !strings.HasSuffix(set, ")") {
+		return Requirement{}, fmt.Errorf("values must be enclosed in parentheses")
+	}
+
+	var values []string
+	for _, v := range strings.Split(set[1:len(set)-1], ",") {
+		v = strings.TrimSpace(v)
+		if !validLabel(v) {
+			return Requirement{}, fmt.Errorf("invalid value '%s'", v)
+		}
+		values = append(values, v)
+	}
+
+	return Requirement{Key: key, Operator: operator, Values: values}, nil
+}
+
+func splitSelector(s string) []string {
+	var parts []string
+	var depth, start int
+	for i, c := range s {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func validLabel(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("-_./", c)) {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
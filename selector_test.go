@@ -0,0 +1,123 @@
+package p2p_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/yaien/p2p"
+)
+
+func TestParseSelector(t *testing.T) {
+	labels := map[string]string{"role": "worker", "region": "us", "tier": "gold"}
+
+	cases := []struct {
+		selector string
+		matches  bool
+	}{
+		{"", true},
+		{"role=worker", true},
+		{"role==worker", true},
+		{"role=worker,region!=eu", true},
+		{"role=worker,region!=us", false},
+		{"tier in (gold, silver)", true},
+		{"tier notin (gold,silver),role=worker", false},
+		{"role", true},
+		{"!canary", true},
+		{"!role", false},
+		{"missing!=value", true},
+	}
+
+	for _, c := range cases {
+		s, err := p2p.ParseSelector(c.selector)
+		if err != nil {
+			t.Errorf("failed parsing %q: %s", c.selector, err)
+			continue
+		}
+
+		if s.Matches(labels) != c.matches {
+			t.Errorf("expected %q matches to be %v", c.selector, c.matches)
+		}
+	}
+
+	for _, invalid := range []string{"role in worker", "role ~ worker", "ro le=worker"} {
+		_, err := p2p.ParseSelector(invalid)
+		if err == nil {
+			t.Errorf("expected %q to be invalid", invalid)
+		}
+	}
+}
+
+func TestP2P_Http_RequestSelector(t *testing.T) {
+	transport := &p2p.HttpTransport{}
+	from := p2p.New(p2p.Options{Transport: transport})
+
+	mx := http.NewServeMux()
+	srv := httptest.NewServer(mx)
+	defer srv.Close()
+
+	to := p2p.New(p2p.Options{Addr: srv.URL, Name: "target-p2p", Labels: map[string]string{"role": "worker"}, Transport: transport})
+	p2p.NewHttpServer(to, nil, "").Register(mx)
+
+	handler, called := mockup(func(ctx context.Context, m *p2p.MessageRequest) ([]byte, error) {
+		return []byte(`{ "message": "received" }`), nil
+	})
+
+	to.Handle(handler)
+
+	err := from.Discover(to.CurrentAddr())
+	if err != nil {
+		t.Fatalf("failed at discover: %s", err)
+	}
+
+	_, err = from.RequestSelector("role=leader", "message", []byte(`{}`))
+	if err == nil {
+		t.Fatal("expected no peer to match role=leader")
+	}
+
+	_, err = from.RequestSelector("role=worker", "message", []byte(`{}`))
+	if err != nil {
+		t.Fatalf("failed at request: %s", err)
+	}
+
+	if !*called {
+		t.Error("custom handler was no called")
+	}
+}
+
+func TestP2P_Http_RequestSelectorContext(t *testing.T) {
+	transport := &p2p.HttpTransport{}
+	from := p2p.New(p2p.Options{Transport: transport})
+
+	mx := http.NewServeMux()
+	srv := httptest.NewServer(mx)
+	defer srv.Close()
+
+	to := p2p.New(p2p.Options{Addr: srv.URL, Name: "target-p2p", Labels: map[string]string{"role": "worker"}, Transport: transport})
+	p2p.NewHttpServer(to, nil, "").Register(mx)
+
+	to.HandleFunc(func(ctx context.Context, r *p2p.MessageRequest) ([]byte, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	err := from.Discover(to.CurrentAddr())
+	if err != nil {
+		t.Fatalf("failed at discover: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err = from.RequestSelectorContext(ctx, "role=worker", "message", []byte(`{}`))
+	if err == nil {
+		t.Fatal("expected request to fail after the deadline")
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected request to end with the caller deadline, took %s", elapsed)
+	}
+}
@@ -2,40 +2,122 @@ package p2p
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"net"
+	"net/http"
+	"sync"
+	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
 )
 
 type GrpcServer struct {
 	UnimplementedP2PServer
+	TLSConfig  *tls.Config
 	p2p        *P2P
 	subscriber *Subscriber
+	verifier   *verifier
+	once       sync.Once
 	server     *grpc.Server
 }
 
-func NewGrpcServer(p2p *P2P, s *Subscriber) *GrpcServer {
-	return &GrpcServer{p2p: p2p, subscriber: s}
+func NewGrpcServer(p2p *P2P, s *Subscriber, key string) *GrpcServer {
+	return &GrpcServer{p2p: p2p, subscriber: s, verifier: newVerifier(key)}
+}
+
+// SetSkew sets how far a request timestamp may be from the local clock.
+func (s *GrpcServer) SetSkew(skew time.Duration) {
+	s.verifier.skew = skew
 }
 
 func (s *GrpcServer) Serve(lis net.Listener) error {
-	s.server = grpc.NewServer()
-	RegisterP2PServer(s.server, s)
-	reflection.Register(s.server)
-	return s.server.Serve(lis)
+	return s.grpcServer().Serve(lis)
+}
+
+// ServeHTTP serves grpc calls arriving through an http/2 server, as done by
+// the MuxServer, the TLSConfig is left to the MuxServer TLSConfig then.
+func (s *GrpcServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.grpcServer().ServeHTTP(w, r)
+}
+
+func (s *GrpcServer) grpcServer() *grpc.Server {
+	s.once.Do(func() {
+		opts := []grpc.ServerOption{grpc.UnaryInterceptor(s.verify), grpc.StreamInterceptor(s.verifyStream)}
+		if s.TLSConfig != nil {
+			opts = append(opts, grpc.Creds(credentials.NewTLS(s.TLSConfig)))
+		}
+
+		s.server = grpc.NewServer(opts...)
+		RegisterP2PServer(s.server, s)
+		reflection.Register(s.server)
+	})
+	return s.server
+}
+
+// verify checks the request signature set by the GrpcTransport before
+// handing it to the unary methods.
+func (s *GrpcServer) verify(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	data, err := signedBytes(req)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	err = s.verifyMetadata(ctx, info.FullMethod, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+// verifyStream checks the signature of a stream opening, signed over an
+// empty body by the GrpcTransport.
+func (s *GrpcServer) verifyStream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	err := s.verifyMetadata(ss.Context(), info.FullMethod, nil)
+	if err != nil {
+		return err
+	}
+
+	return handler(srv, ss)
+}
+
+func (s *GrpcServer) verifyMetadata(ctx context.Context, method string, data []byte) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	get := func(key string) string {
+		values := md.Get(key)
+		if len(values) == 0 {
+			return ""
+		}
+		return values[0]
+	}
+
+	err := s.verifier.verify("POST", method, get("x-timestamp"), get("x-nonce"), get("x-signature"), data)
+	if err != nil {
+		return status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	return nil
 }
 
 func (s *GrpcServer) Close() error {
-	s.server.Stop()
+	s.grpcServer().Stop()
 	return nil
 }
 
 func (s *GrpcServer) Connect(ctx context.Context, r *ConnectRequest) (*ConnectResponse, error) {
-	s.p2p.Save(r.Current)
-	res := &ConnectResponse{State: s.p2p.State()}
-	return res, nil
+	state, err := s.p2p.Accept(r.Current)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	return &ConnectResponse{State: state}, nil
 }
 
 func (s *GrpcServer) State(_ *StateRequest, srv P2P_StateServer) error {
@@ -59,10 +141,28 @@ func (s *GrpcServer) State(_ *StateRequest, srv P2P_StateServer) error {
 }
 
 func (s *GrpcServer) Message(ctx context.Context, r *MessageRequest) (*MessageResponse, error) {
-	body, err := s.p2p.handler.ServeP2P(ctx, r)
+	res, err := s.p2p.serveMessage(ctx, r)
+	if errors.Is(err, ErrInvalidProof) {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	if err != nil {
+		e := toError(err)
+		st, serr := status.New(e.grpcCode(), e.Message).WithDetails(e.status())
+		if serr != nil {
+			return nil, status.Error(e.grpcCode(), e.Message)
+		}
+		return nil, st.Err()
+	}
+
+	return res, nil
+}
+
+func (s *GrpcServer) Leave(ctx context.Context, r *LeaveRequest) (*LeaveResponse, error) {
+	err := s.p2p.Depart(r.Current)
 	if err != nil {
-		return nil, fmt.Errorf("failed p2p: %w", err)
+		return nil, status.Error(codes.Unauthenticated, err.Error())
 	}
 
-	return &MessageResponse{Body: body}, nil
+	return &LeaveResponse{}, nil
 }
@@ -1,30 +1,57 @@
 package p2p
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"time"
 )
 
 type HttpServer struct {
 	p2p        *P2P
 	subscriber *Subscriber
-	key        string
+	verifier   *verifier
 	http.Server
 }
 
 func NewHttpServer(p *P2P, s *Subscriber, key string) *HttpServer {
-	h := &HttpServer{p2p: p, subscriber: s, key: key}
+	h := &HttpServer{p2p: p, subscriber: s, verifier: newVerifier(key)}
 	handler := http.NewServeMux()
 	h.Register(handler)
 	h.Server.Handler = handler
 	return h
 }
 
+// SetSkew sets how far a request timestamp may be from the local clock.
+func (s *HttpServer) SetSkew(skew time.Duration) {
+	s.verifier.skew = skew
+}
+
+// Serve serves on l, over tls when the server TLSConfig is set.
+func (s *HttpServer) Serve(l net.Listener) error {
+	return s.Server.Serve(tlsListener(l, s.TLSConfig, "http/1.1"))
+}
+
 // HttpAPIHandle set the p2p connection endpoints
 func (s *HttpServer) Register(mx *http.ServeMux) {
 
 	mx.HandleFunc("/p2p/state", func(w http.ResponseWriter, r *http.Request) {
+		// without a key the stream stays open to browsers, which can't sign
+		if s.verifier.key != "" {
+			h := r.Header
+			err := s.verifier.verify(r.Method, r.URL.Path, h.Get("X-Timestamp"), h.Get("X-Nonce"), h.Get("X-Signature"), nil)
+			if err != nil {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusUnauthorized)
+				json.NewEncoder(w).Encode(map[string]any{"error": err.Error()})
+				return
+			}
+		}
+
 		h := w.Header()
 		h.Set("Content-Type", "text/event-stream")
 		h.Set("Cache-Control", "no-cache")
@@ -58,22 +85,39 @@ func (s *HttpServer) Register(mx *http.ServeMux) {
 		w.Header().Set("Content-Type", "application/json")
 
 		var peer Peer
-		err := json.NewDecoder(r.Body).Decode(&peer)
+		if !s.decode(w, r, &peer) {
+			return
+		}
+
+		state, err := s.p2p.Accept(&peer)
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
+			w.WriteHeader(http.StatusUnauthorized)
 			json.NewEncoder(w).Encode(map[string]any{"error": err.Error()})
 			return
 		}
 
-		if r.Header.Get("X-Signature") != signature(&peer, s.key) {
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(state)
+	})
+
+	mx.HandleFunc("/p2p/leave", func(w http.ResponseWriter, r *http.Request) {
+
+		w.Header().Set("Content-Type", "application/json")
+
+		var peer Peer
+		if !s.decode(w, r, &peer) {
+			return
+		}
+
+		err := s.p2p.Depart(&peer)
+		if err != nil {
 			w.WriteHeader(http.StatusUnauthorized)
-			json.NewEncoder(w).Encode(map[string]any{"error": "invalid signature"})
+			json.NewEncoder(w).Encode(map[string]any{"error": err.Error()})
 			return
 		}
 
-		s.p2p.Save(&peer)
 		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(s.p2p.State())
+		json.NewEncoder(w).Encode(map[string]any{})
 	})
 
 	mx.HandleFunc("/p2p/message", func(w http.ResponseWriter, r *http.Request) {
@@ -81,27 +125,67 @@ func (s *HttpServer) Register(mx *http.ServeMux) {
 		w.Header().Set("content-type", "application/json")
 
 		var req HttpMessage
-		err := json.NewDecoder(r.Body).Decode(&req)
-		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(map[string]any{"error": err.Error()})
+		if !s.decode(w, r, &req) {
 			return
 		}
 
-		if r.Header.Get("X-Signature") != signature(req.From, s.key) {
+		ctx, cancel := withTimeout(r)
+		defer cancel()
+
+		res, err := s.p2p.serveMessage(ctx, &MessageRequest{From: req.From, Subject: req.Subject, Body: joinBody(req.Body, req.Data), ContentType: req.ContentType, Headers: req.Headers})
+		if errors.Is(err, ErrInvalidProof) {
 			w.WriteHeader(http.StatusUnauthorized)
-			json.NewEncoder(w).Encode(map[string]any{"error": "invalid signature"})
+			json.NewEncoder(w).Encode(map[string]any{"error": err.Error()})
 			return
 		}
 
-		body, err := s.p2p.handler.ServeP2P(r.Context(), &MessageRequest{From: req.From, Subject: req.Subject, Body: req.Body})
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(map[string]any{"error": err.Error()})
+			e := toError(err)
+			w.WriteHeader(e.httpStatus())
+			json.NewEncoder(w).Encode(&HttpMessageReply{Error: e.Message, Code: string(e.Code), Details: e.Details})
 			return
 		}
 
+		reply := HttpMessageReply{Headers: res.Headers}
+		reply.Body, reply.Data = splitBody(res.Body)
+
 		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(&HttpMessageReply{Body: body})
+		json.NewEncoder(w).Encode(&reply)
 	})
 }
+
+// decode verifies the request signature over the raw body before decoding it
+// into v, writing the error response when any of them fails.
+func (s *HttpServer) decode(w http.ResponseWriter, r *http.Request, v any) bool {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]any{"error": err.Error()})
+		return false
+	}
+
+	h := r.Header
+	err = s.verifier.verify(r.Method, r.URL.Path, h.Get("X-Timestamp"), h.Get("X-Nonce"), h.Get("X-Signature"), data)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]any{"error": err.Error()})
+		return false
+	}
+
+	err = json.Unmarshal(data, v)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]any{"error": err.Error()})
+		return false
+	}
+
+	return true
+}
+
+func withTimeout(r *http.Request) (context.Context, context.CancelFunc) {
+	timeout, err := time.ParseDuration(r.Header.Get("X-Timeout"))
+	if err != nil || timeout <= 0 {
+		return context.WithCancel(r.Context())
+	}
+	return context.WithTimeout(r.Context(), timeout)
+}
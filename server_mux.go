@@ -0,0 +1,49 @@
+package p2p
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// MuxServer serves the http, websocket and grpc protocols on a single
+// listener, telling grpc calls apart by their http/2 content-type. With a
+// TLSConfig all of them are served over tls.
+type MuxServer struct {
+	TLSConfig *tls.Config
+	grpc      *GrpcServer
+	mux       *http.ServeMux
+	server    http.Server
+}
+
+func NewMuxServer(p *P2P, s *Subscriber, key string) *MuxServer {
+	m := &MuxServer{grpc: NewGrpcServer(p, s, key), mux: http.NewServeMux()}
+	NewHttpServer(p, s, key).Register(m.mux)
+	NewWebSocketServer(p, s, key).Register(m.mux)
+	h2 := &http2.Server{}
+	m.server.Handler = h2c.NewHandler(m, h2)
+	http2.ConfigureServer(&m.server, h2)
+	return m
+}
+
+func (m *MuxServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+		m.grpc.ServeHTTP(w, r)
+		return
+	}
+
+	m.mux.ServeHTTP(w, r)
+}
+
+func (m *MuxServer) Serve(l net.Listener) error {
+	return m.server.Serve(tlsListener(l, m.TLSConfig, "h2", "http/1.1"))
+}
+
+func (m *MuxServer) Close() error {
+	m.grpc.Close()
+	return m.server.Close()
+}
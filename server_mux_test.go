@@ -0,0 +1,99 @@
+package p2p_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/yaien/p2p"
+	"github.com/yaien/p2p/p2ptest"
+	"golang.org/x/net/nettest"
+)
+
+func multiTransport(key string) *p2p.MultiTransport {
+	return &p2p.MultiTransport{
+		Transports: map[string]p2p.Transport{
+			p2p.ProtocolHttp:      &p2p.HttpTransport{Key: key},
+			p2p.ProtocolGrpc:      &p2p.GrpcTransport{Key: key},
+			p2p.ProtocolWebSocket: &p2p.WebSocketTransport{Key: key},
+		},
+		Preference: []string{p2p.ProtocolGrpc, p2p.ProtocolWebSocket, p2p.ProtocolHttp},
+	}
+}
+
+func serveMux(tb testing.TB, p *p2p.P2P, key string) string {
+	lis, err := nettest.NewLocalListener("tcp")
+	if err != nil {
+		tb.Fatalf("failed creating testing listener: %s", err)
+	}
+	srv := p2p.NewMuxServer(p, nil, key)
+	go srv.Serve(lis)
+	tb.Cleanup(func() { srv.Close() })
+	addr := "http://" + lis.Addr().String()
+	p.SetCurrentAddr(addr)
+	return addr
+}
+
+func TestMuxServer_Conformance(t *testing.T) {
+	for name, transport := range map[string]func(key string) p2p.Transport{
+		"Http":  func(key string) p2p.Transport { return &p2p.HttpTransport{Key: key} },
+		"Multi": func(key string) p2p.Transport { return multiTransport(key) },
+	} {
+		transport := transport
+		t.Run(name, func(t *testing.T) {
+			p2ptest.TestTransport(t, p2ptest.Factory{NewTransport: transport, Serve: serveMux})
+		})
+	}
+}
+
+func TestMultiTransport_MixedFleet(t *testing.T) {
+	echo := func(ctx context.Context, r *p2p.MessageRequest) ([]byte, error) {
+		return r.Body, nil
+	}
+
+	mux := p2p.New(p2p.Options{Name: "mux-p2p", Transport: multiTransport("")})
+	serveMux(t, mux, "")
+
+	mx := http.NewServeMux()
+	srv := httptest.NewServer(mx)
+	defer srv.Close()
+
+	rest := p2p.New(p2p.Options{Addr: srv.URL, Name: "rest-p2p", Protocols: []string{p2p.ProtocolHttp}, Transport: &p2p.HttpTransport{}})
+	rest.HandleFunc(echo)
+	p2p.NewHttpServer(rest, nil, "").Register(mx)
+
+	lis, err := nettest.NewLocalListener("tcp")
+	if err != nil {
+		t.Fatalf("failed creating testing listener: %s", err)
+	}
+
+	grpc := p2p.New(p2p.Options{Addr: lis.Addr().String(), Name: "grpc-p2p", Protocols: []string{p2p.ProtocolGrpc}, Transport: &p2p.GrpcTransport{}})
+	grpc.HandleFunc(echo)
+	gs := p2p.NewGrpcServer(grpc, nil, "")
+	go gs.Serve(lis)
+	defer gs.Close()
+
+	err = rest.Discover(mux.CurrentAddr())
+	if err != nil {
+		t.Fatalf("failed at rest discover: %s", err)
+	}
+
+	err = grpc.Discover(strings.TrimPrefix(mux.CurrentAddr(), "http://"))
+	if err != nil {
+		t.Fatalf("failed at grpc discover: %s", err)
+	}
+
+	for _, name := range []string{"rest-p2p", "grpc-p2p"} {
+		reply, err := mux.Request(name, "echo", []byte(`{}`))
+		if err != nil {
+			t.Errorf("failed at request to %s: %s", name, err)
+			continue
+		}
+
+		if string(reply) != `{}` {
+			t.Errorf("unexpected reply from %s: %s", name, reply)
+		}
+	}
+}
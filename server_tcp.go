@@ -0,0 +1,232 @@
+package p2p
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type TcpServer struct {
+	TLSConfig  *tls.Config
+	p2p        *P2P
+	subscriber *Subscriber
+	verifier   *verifier
+	mutex      sync.Mutex
+	listener   net.Listener
+	conns      map[net.Conn]bool
+	closed     bool
+}
+
+func NewTcpServer(p *P2P, s *Subscriber, key string) *TcpServer {
+	return &TcpServer{p2p: p, subscriber: s, verifier: newVerifier(key), conns: make(map[net.Conn]bool)}
+}
+
+// SetSkew sets how far a handshake timestamp may be from the local clock.
+func (s *TcpServer) SetSkew(skew time.Duration) {
+	s.verifier.skew = skew
+}
+
+func (s *TcpServer) Serve(l net.Listener) error {
+	if s.TLSConfig != nil {
+		l = tls.NewListener(l, s.TLSConfig)
+	}
+
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		l.Close()
+		return net.ErrClosed
+	}
+	s.listener = l
+	s.mutex.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return fmt.Errorf("failed accepting connection: %w", err)
+		}
+
+		go s.serve(conn)
+	}
+}
+
+func (s *TcpServer) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.closed = true
+	for conn := range s.conns {
+		conn.Close()
+	}
+
+	if s.listener != nil {
+		return s.listener.Close()
+	}
+	return nil
+}
+
+func (s *TcpServer) track(conn net.Conn, open bool) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !open {
+		delete(s.conns, conn)
+		return false
+	}
+
+	if s.closed {
+		return false
+	}
+	s.conns[conn] = true
+	return true
+}
+
+func (s *TcpServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	if !s.track(conn, true) {
+		return
+	}
+	defer s.track(conn, false)
+
+	var write sync.Mutex
+	send := func(f *Frame) error {
+		write.Lock()
+		defer write.Unlock()
+		return writeFrame(conn, f)
+	}
+
+	r := bufio.NewReader(conn)
+	err := s.handshake(conn, r)
+	if err != nil {
+		send(&Frame{Error: err.Error()})
+		return
+	}
+
+	err = send(&Frame{Payload: &Frame_Handshake{Handshake: &Handshake{}}})
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if s.subscriber != nil {
+		go s.push(ctx, send)
+	}
+
+	// frames are handled concurrently up to maxConnFrames, the next ones
+	// aren't read until a slot frees up
+	slots := make(chan struct{}, maxConnFrames)
+	for {
+		f, err := readFrame(r, maxFrameSize)
+		if err != nil {
+			return
+		}
+
+		slots <- struct{}{}
+		go func() {
+			defer func() { <-slots }()
+			reply := s.handle(ctx, f)
+			reply.Id = f.Id
+			send(reply)
+		}()
+	}
+}
+
+// handshake verifies the signed frame a TcpTransport sends first on every
+// connection.
+func (s *TcpServer) handshake(conn net.Conn, r *bufio.Reader) error {
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	defer conn.SetReadDeadline(time.Time{})
+
+	f, err := readFrame(r, maxHandshakeSize)
+	if err != nil {
+		return fmt.Errorf("failed reading handshake: %w", err)
+	}
+
+	h := f.GetHandshake()
+	if h == nil {
+		return fmt.Errorf("expected handshake, got %T", f.Payload)
+	}
+
+	return s.verifier.verify("TCP", "/p2p", strconv.FormatInt(h.Timestamp, 10), h.Nonce, h.Signature, nil)
+}
+
+// handle replies to a frame, a panic is replied as an internal error rather
+// than taking the whole node down.
+func (s *TcpServer) handle(ctx context.Context, f *Frame) (reply *Frame) {
+	defer func() {
+		v := recover()
+		if v != nil {
+			log.Printf("panic serving tcp frame %T: %v\n%s", f.Payload, v, debug.Stack())
+			e := Errorf(CodeInternal, "panic serving frame: %v", v)
+			reply = &Frame{Error: e.Message, Status: e.status()}
+		}
+	}()
+
+	switch payload := f.Payload.(type) {
+	case *Frame_Connect:
+		state, err := s.p2p.Accept(payload.Connect.GetCurrent())
+		if err != nil {
+			return &Frame{Error: err.Error()}
+		}
+		return &Frame{Payload: &Frame_Connected{Connected: &ConnectResponse{State: state}}}
+
+	case *Frame_Leave:
+		err := s.p2p.Depart(payload.Leave.GetCurrent())
+		if err != nil {
+			return &Frame{Error: err.Error()}
+		}
+		return &Frame{Payload: &Frame_Left{Left: &LeaveResponse{}}}
+
+	case *Frame_Message:
+		if f.TimeoutMs > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, time.Duration(f.TimeoutMs)*time.Millisecond)
+			defer cancel()
+		}
+
+		res, err := s.p2p.serveMessage(ctx, payload.Message)
+		if err != nil {
+			e := toError(err)
+			return &Frame{Error: e.Message, Status: e.status()}
+		}
+		return &Frame{Payload: &Frame_Reply{Reply: res}}
+
+	default:
+		return &Frame{Error: fmt.Sprintf("unexpected frame %T", f.Payload)}
+	}
+}
+
+// push forwards every state update to the connected peer.
+func (s *TcpServer) push(ctx context.Context, send func(*Frame) error) {
+	updated, unsubscribe := s.subscriber.Subscribe()
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case state, ok := <-updated:
+			if !ok {
+				return
+			}
+			err := send(&Frame{Payload: &Frame_State{State: &StateResponse{State: state}}})
+			if err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package p2p
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+type WebSocketServer struct {
+	p2p        *P2P
+	subscriber *Subscriber
+	verifier   *verifier
+	http.Server
+}
+
+func NewWebSocketServer(p *P2P, s *Subscriber, key string) *WebSocketServer {
+	ws := &WebSocketServer{p2p: p, subscriber: s, verifier: newVerifier(key)}
+	handler := http.NewServeMux()
+	ws.Register(handler)
+	ws.Server.Handler = handler
+	return ws
+}
+
+// SetSkew sets how far a handshake timestamp may be from the local clock.
+func (s *WebSocketServer) SetSkew(skew time.Duration) {
+	s.verifier.skew = skew
+}
+
+// Serve serves on l, over tls when the server TLSConfig is set.
+func (s *WebSocketServer) Serve(l net.Listener) error {
+	return s.Server.Serve(tlsListener(l, s.TLSConfig, "http/1.1"))
+}
+
+// Register sets the websocket endpoint, it can share a mux with an
+// HttpServer so both transports are served on the same port.
+func (s *WebSocketServer) Register(mx *http.ServeMux) {
+	mx.Handle("/p2p/ws", websocket.Server{Handshake: s.handshake, Handler: s.serve})
+}
+
+// handshake verifies the signature the WebSocketTransport sets on the
+// upgrade request, once per connection.
+func (s *WebSocketServer) handshake(config *websocket.Config, r *http.Request) error {
+	h := r.Header
+	err := s.verifier.verify(r.Method, r.URL.Path, h.Get("X-Timestamp"), h.Get("X-Nonce"), h.Get("X-Signature"), nil)
+	if err != nil {
+		return fmt.Errorf("failed verifying handshake: %w", err)
+	}
+	return nil
+}
+
+func (s *WebSocketServer) serve(ws *websocket.Conn) {
+	defer ws.Close()
+
+	ctx, cancel := context.WithCancel(ws.Request().Context())
+	defer cancel()
+
+	if s.subscriber != nil {
+		go s.push(ctx, ws)
+	}
+
+	// frames are handled concurrently up to maxConnFrames, the next ones
+	// aren't read until a slot frees up
+	slots := make(chan struct{}, maxConnFrames)
+	for {
+		var frame wsFrame
+		err := websocket.JSON.Receive(ws, &frame)
+		if err != nil {
+			return
+		}
+
+		slots <- struct{}{}
+		go func() {
+			defer func() { <-slots }()
+			reply := s.handle(ctx, &frame)
+			reply.Id, reply.Type = frame.Id, wsReply
+			websocket.JSON.Send(ws, reply)
+		}()
+	}
+}
+
+// maxConnFrames bounds the frames served at once on a single connection.
+const maxConnFrames = 64
+
+// handle replies to a frame, a panic is replied as an internal error rather
+// than taking the whole node down.
+func (s *WebSocketServer) handle(ctx context.Context, frame *wsFrame) (reply *wsFrame) {
+	defer func() {
+		v := recover()
+		if v != nil {
+			log.Printf("panic serving websocket frame '%s': %v\n%s", frame.Type, v, debug.Stack())
+			reply = &wsFrame{Error: fmt.Sprintf("panic serving frame: %v", v), Code: string(CodeInternal)}
+		}
+	}()
+
+	switch frame.Type {
+	case wsConnect:
+		state, err := s.p2p.Accept(frame.Peer)
+		if err != nil {
+			return &wsFrame{Error: err.Error()}
+		}
+		return &wsFrame{State: state}
+
+	case wsLeave:
+		err := s.p2p.Depart(frame.Peer)
+		if err != nil {
+			return &wsFrame{Error: err.Error()}
+		}
+		return &wsFrame{}
+
+	case wsMessage:
+		timeout, err := time.ParseDuration(frame.Timeout)
+		if err == nil && timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		res, err := s.p2p.serveMessage(ctx, &MessageRequest{From: frame.Peer, Subject: frame.Subject, Body: frame.Body, ContentType: frame.ContentType, Headers: frame.Headers})
+		if err != nil {
+			e := toError(err)
+			return &wsFrame{Error: e.Message, Code: string(e.Code), Details: e.Details}
+		}
+		return &wsFrame{Body: res.Body, Headers: res.Headers}
+
+	default:
+		return &wsFrame{Error: fmt.Sprintf("unexpected frame type '%s'", frame.Type)}
+	}
+}
+
+// push forwards every state update to the connected peer.
+func (s *WebSocketServer) push(ctx context.Context, ws *websocket.Conn) {
+	updated, unsubscribe := s.subscriber.Subscribe()
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case state, ok := <-updated:
+			if !ok {
+				return
+			}
+			err := websocket.JSON.Send(ws, &wsFrame{Type: wsState, State: state})
+			if err != nil {
+				return
+			}
+		}
+	}
+}
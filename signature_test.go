@@ -0,0 +1,92 @@
+package p2p_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/yaien/p2p"
+)
+
+func TestHttpServer_Signature(t *testing.T) {
+	var captured *http.Request
+	var body []byte
+
+	mx := http.NewServeMux()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		captured = r.Clone(r.Context())
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		mx.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	to := p2p.New(p2p.Options{Addr: srv.URL, Name: "target-p2p", Transport: &p2p.HttpTransport{Key: "secret"}})
+	p2p.NewHttpServer(to, nil, "secret").Register(mx)
+
+	intruder := p2p.New(p2p.Options{Transport: &p2p.HttpTransport{Key: "wrong"}})
+	err := intruder.Discover(to.CurrentAddr())
+	if err == nil {
+		t.Fatal("expected discover with a wrong key to fail")
+	}
+
+	from := p2p.New(p2p.Options{Transport: &p2p.HttpTransport{Key: "secret"}})
+	err = from.Discover(to.CurrentAddr())
+	if err != nil {
+		t.Fatalf("failed at discover: %s", err)
+	}
+
+	replay := func(body []byte) int {
+		req, _ := http.NewRequest("POST", srv.URL+captured.URL.Path, bytes.NewReader(body))
+		req.Header = captured.Header.Clone()
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("failed replaying request: %s", err)
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+
+	if status := replay(body); status != http.StatusUnauthorized {
+		t.Errorf("expected replayed request to be rejected, got status %d", status)
+	}
+
+	tampered := bytes.Replace(body, []byte(`"id"`), []byte(`"Id"`), 1)
+	if status := replay(tampered); status != http.StatusUnauthorized {
+		t.Errorf("expected tampered request to be rejected, got status %d", status)
+	}
+}
+
+func TestHttpServer_Signature_State(t *testing.T) {
+	mx := http.NewServeMux()
+	srv := httptest.NewServer(mx)
+	defer srv.Close()
+
+	to := p2p.New(p2p.Options{Addr: srv.URL, Name: "target-p2p", Transport: &p2p.HttpTransport{Key: "secret"}})
+	p2p.NewHttpServer(to, p2p.NewSubscriber(to.Channel()), "secret").Register(mx)
+	defer to.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	err := p2p.Subscribe2Http(ctx, srv.URL, make(chan *p2p.State, 1))
+	if err == nil {
+		t.Fatal("expected an unsigned state subscription to fail")
+	}
+
+	out := make(chan *p2p.State, 1)
+	go p2p.HttpSubscriber("secret")(ctx, srv.URL, out)
+
+	select {
+	case state := <-out:
+		if state.Current.Name != "target-p2p" {
+			t.Errorf("unexpected state of %s", state.Current.Name)
+		}
+	case <-ctx.Done():
+		t.Fatal("expected a signed state subscription to receive the state")
+	}
+}
@@ -15,8 +15,10 @@ func NewSubscriber(source <-chan *State) *Subscriber {
 	return s
 }
 
+// Subscribe returns a channel holding the latest state, a subscription not
+// keeping up misses the states in between rather than stalling the others.
 func (s *Subscriber) Subscribe() (<-chan *State, UnsubscribeFunc) {
-	channel := make(chan *State)
+	channel := make(chan *State, 1)
 	s.subscriptions.Store(channel, true)
 	unsubscribe := func() {
 		s.subscriptions.Delete(channel)
@@ -28,8 +30,24 @@ func (s *Subscriber) start() {
 	for state := range s.source {
 		s.subscriptions.Range(func(key, _ any) bool {
 			subscription := key.(chan *State)
-			subscription <- state
+			select {
+			case subscription <- state:
+			default:
+				// drop the oldest state, start is the only sender so there is
+				// room for the new one afterwards
+				select {
+				case <-subscription:
+				default:
+				}
+				subscription <- state
+			}
 			return true
 		})
 	}
+
+	s.subscriptions.Range(func(key, _ any) bool {
+		close(key.(chan *State))
+		s.subscriptions.Delete(key)
+		return true
+	})
 }
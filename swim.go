@@ -0,0 +1,479 @@
+package p2p
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	swimPrefix  = "p2p.swim."
+	swimPing    = swimPrefix + "ping"
+	swimPingReq = swimPrefix + "ping-req"
+)
+
+// SwimOptions enables the SWIM membership protocol: instead of polling every
+// peer, each period probes one random member, asking k others to probe it
+// indirectly on failure, and gossips membership changes on those messages.
+type SwimOptions struct {
+	Interval         time.Duration
+	Timeout          time.Duration
+	IndirectChecks   int
+	SuspicionTimeout time.Duration
+	Retransmit       int
+	MaxPiggyback     int
+}
+
+func (o *SwimOptions) defaults() {
+	if o.Interval <= 0 {
+		o.Interval = time.Second
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = o.Interval / 2
+	}
+	if o.IndirectChecks <= 0 {
+		o.IndirectChecks = 3
+	}
+	if o.SuspicionTimeout <= 0 {
+		o.SuspicionTimeout = 5 * o.Interval
+	}
+	if o.Retransmit <= 0 {
+		o.Retransmit = 3
+	}
+	if o.MaxPiggyback <= 0 {
+		o.MaxPiggyback = 8
+	}
+}
+
+type swimStatus int
+
+const (
+	swimAlive swimStatus = iota
+	swimSuspect
+	swimDead
+)
+
+func (s swimStatus) peerStatus() PeerStatus {
+	switch s {
+	case swimSuspect:
+		return PeerStatus_SUSPECT
+	case swimDead:
+		return PeerStatus_DEAD
+	default:
+		return PeerStatus_ALIVE
+	}
+}
+
+type swimUpdate struct {
+	Peer        *Peer      `json:"peer"`
+	Status      swimStatus `json:"status"`
+	Incarnation uint64     `json:"incarnation"`
+}
+
+type swimMessage struct {
+	Target  *Peer         `json:"target,omitempty"`
+	Updates []*swimUpdate `json:"updates,omitempty"`
+}
+
+type swimMember struct {
+	peer        *Peer
+	status      swimStatus
+	incarnation uint64
+	changedAt   time.Time
+}
+
+type swimBroadcast struct {
+	update    *swimUpdate
+	transmits int
+}
+
+type swim struct {
+	p2p         *P2P
+	opts        SwimOptions
+	mutex       sync.Mutex
+	incarnation uint64
+	members     map[string]*swimMember
+	queue       []*swimBroadcast
+	probes      []string
+}
+
+func newSwim(p *P2P, opts SwimOptions) *swim {
+	opts.defaults()
+	// starting from the clock keeps a restarted node above the incarnation
+	// it was declared dead at, so its first join revives it.
+	incarnation := uint64(p.clock.Now().Unix())
+	return &swim{p2p: p, opts: opts, members: make(map[string]*swimMember), incarnation: incarnation}
+}
+
+// join adds a peer seen directly, a dead member is only revived by the peer
+// announcing an incarnation newer than the one it was declared dead at.
+func (s *swim) join(peer *Peer) {
+	s.mutex.Lock()
+	m, ok := s.members[peer.Id]
+	if !ok {
+		s.members[peer.Id] = &swimMember{peer: peer, incarnation: peer.Incarnation}
+		s.enqueue(&swimUpdate{Peer: peer, Status: swimAlive, Incarnation: peer.Incarnation})
+		s.mutex.Unlock()
+		return
+	}
+
+	m.peer = peer
+	revived := m.status == swimDead && peer.Incarnation > m.incarnation
+	if revived {
+		m.status, m.incarnation = swimAlive, peer.Incarnation
+		s.enqueue(&swimUpdate{Peer: peer, Status: swimAlive, Incarnation: m.incarnation})
+	}
+	s.mutex.Unlock()
+
+	if revived {
+		s.p2p.setStatus(peer.Id, PeerStatus_ALIVE)
+	}
+}
+
+// self returns the incarnation of the current peer.
+func (s *swim) self() uint64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.incarnation
+}
+
+func (s *swim) leave(peer *Peer) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	m, ok := s.members[peer.Id]
+	if !ok || m.status == swimDead {
+		return
+	}
+
+	m.status = swimDead
+	m.changedAt = s.p2p.clock.Now()
+	s.enqueue(&swimUpdate{Peer: m.peer, Status: swimDead, Incarnation: m.incarnation})
+}
+
+func (s *swim) probe(ctx context.Context) {
+	target := s.next()
+	if target == nil {
+		s.expire()
+		return
+	}
+
+	_, err := s.ping(ctx, target)
+	if err != nil {
+		if !s.indirect(ctx, target) {
+			s.suspect(target)
+		}
+	}
+
+	s.expire()
+}
+
+func (s *swim) next() *Peer {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for {
+		if len(s.probes) == 0 {
+			for id, m := range s.members {
+				if m.status != swimDead {
+					s.probes = append(s.probes, id)
+				}
+			}
+			if len(s.probes) == 0 {
+				return nil
+			}
+			rand.Shuffle(len(s.probes), func(i, j int) {
+				s.probes[i], s.probes[j] = s.probes[j], s.probes[i]
+			})
+		}
+
+		id := s.probes[0]
+		s.probes = s.probes[1:]
+		m, ok := s.members[id]
+		if ok && m.status != swimDead {
+			return m.peer
+		}
+	}
+}
+
+func (s *swim) ping(ctx context.Context, target *Peer) (*swimMessage, error) {
+	return s.send(ctx, target, swimPing, &swimMessage{}, s.opts.Timeout)
+}
+
+func (s *swim) indirect(ctx context.Context, target *Peer) bool {
+	helpers := s.helpers(target.Id)
+	if len(helpers) == 0 {
+		return false
+	}
+
+	acks := make(chan bool, len(helpers))
+	for _, helper := range helpers {
+		go func(helper *Peer) {
+			_, err := s.send(ctx, helper, swimPingReq, &swimMessage{Target: target}, 2*s.opts.Timeout)
+			acks <- err == nil
+		}(helper)
+	}
+
+	for range helpers {
+		if <-acks {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *swim) helpers(exclude string) []*Peer {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var candidates []*Peer
+	for id, m := range s.members {
+		if id != exclude && m.status == swimAlive {
+			candidates = append(candidates, m.peer)
+		}
+	}
+
+	rand.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+
+	if len(candidates) > s.opts.IndirectChecks {
+		candidates = candidates[:s.opts.IndirectChecks]
+	}
+
+	return candidates
+}
+
+func (s *swim) send(ctx context.Context, to *Peer, subject string, msg *swimMessage, timeout time.Duration) (*swimMessage, error) {
+	msg.Updates = s.piggyback()
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed encoding swim message: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	reply, err := s.p2p.transport.Send(ctx, s.p2p.sign(proofMessage, to.Id, subject, body), to, subject, body)
+	if err != nil {
+		return nil, err
+	}
+
+	s.p2p.heartbeat(to.Id)
+
+	var ack swimMessage
+	err = json.Unmarshal(reply, &ack)
+	if err != nil {
+		return nil, fmt.Errorf("failed decoding swim ack: %w", err)
+	}
+
+	s.apply(ack.Updates)
+	return &ack, nil
+}
+
+func (s *swim) ServeP2P(ctx context.Context, r *MessageRequest) ([]byte, error) {
+	var msg swimMessage
+	err := json.Unmarshal(r.Body, &msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed decoding swim message: %w", err)
+	}
+
+	if r.From != nil {
+		s.p2p.register(r.From, true)
+		s.p2p.heartbeat(r.From.Id)
+	}
+
+	// a ping-req probes the record stored for the target, looked up before
+	// the updates it carries could add it, never the one the requester sent
+	var target *Peer
+	if msg.Target != nil {
+		s.mutex.Lock()
+		m, ok := s.members[msg.Target.Id]
+		if ok {
+			target = m.peer
+		}
+		s.mutex.Unlock()
+	}
+
+	s.apply(msg.Updates)
+
+	switch r.Subject {
+	case swimPing:
+	case swimPingReq:
+		if msg.Target == nil {
+			return nil, errors.New("missing ping-req target")
+		}
+		if target == nil {
+			return nil, Errorf(CodeNotFound, "unknown ping-req target '%s'", msg.Target.Id)
+		}
+		_, err := s.ping(ctx, target)
+		if err != nil {
+			return nil, fmt.Errorf("failed indirect probe: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unknown swim subject '%s'", r.Subject)
+	}
+
+	return json.Marshal(&swimMessage{Updates: s.piggyback()})
+}
+
+func (s *swim) suspect(peer *Peer) {
+	s.mutex.Lock()
+	m, ok := s.members[peer.Id]
+	if !ok || m.status != swimAlive {
+		s.mutex.Unlock()
+		return
+	}
+
+	m.status = swimSuspect
+	m.changedAt = s.p2p.clock.Now()
+	s.enqueue(&swimUpdate{Peer: m.peer, Status: swimSuspect, Incarnation: m.incarnation})
+	s.mutex.Unlock()
+
+	s.p2p.setStatus(peer.Id, PeerStatus_SUSPECT)
+}
+
+func (s *swim) expire() {
+	s.mutex.Lock()
+	var dead []*Peer
+	for id, m := range s.members {
+		if m.status == swimDead && s.p2p.clock.Now().Sub(m.changedAt) > s.opts.SuspicionTimeout {
+			delete(s.members, id)
+			continue
+		}
+
+		if m.status == swimSuspect && s.p2p.clock.Now().Sub(m.changedAt) > s.opts.SuspicionTimeout {
+			m.status = swimDead
+			m.changedAt = s.p2p.clock.Now()
+			s.enqueue(&swimUpdate{Peer: m.peer, Status: swimDead, Incarnation: m.incarnation})
+			dead = append(dead, m.peer)
+		}
+	}
+	s.mutex.Unlock()
+
+	for _, peer := range dead {
+		s.p2p.setStatus(peer.Id, PeerStatus_DEAD)
+	}
+
+	if len(dead) > 0 {
+		s.p2p.notify()
+	}
+}
+
+func (s *swim) apply(updates []*swimUpdate) {
+	var added []*Peer
+	changes := make(map[string]PeerStatus)
+
+	s.mutex.Lock()
+	for _, u := range updates {
+		if u.Peer == nil {
+			continue
+		}
+
+		if u.Peer.Id == s.p2p.current.Id {
+			if u.Status != swimAlive && u.Incarnation >= s.incarnation {
+				s.incarnation = u.Incarnation + 1
+				s.enqueue(&swimUpdate{Peer: s.p2p.current, Status: swimAlive, Incarnation: s.incarnation})
+			}
+			continue
+		}
+
+		m, ok := s.members[u.Peer.Id]
+		if !ok {
+			if u.Status == swimDead {
+				continue
+			}
+			s.members[u.Peer.Id] = &swimMember{peer: u.Peer, status: u.Status, incarnation: u.Incarnation, changedAt: s.p2p.clock.Now()}
+			s.enqueue(u)
+			added = append(added, u.Peer)
+			changes[u.Peer.Id] = u.Status.peerStatus()
+			continue
+		}
+
+		switch u.Status {
+		case swimAlive:
+			if u.Incarnation <= m.incarnation {
+				continue
+			}
+			if m.status == swimDead {
+				added = append(added, u.Peer)
+			}
+			m.peer, m.status, m.incarnation = u.Peer, swimAlive, u.Incarnation
+		case swimSuspect:
+			if m.status == swimDead || u.Incarnation < m.incarnation || (m.status == swimSuspect && u.Incarnation == m.incarnation) {
+				continue
+			}
+			m.status, m.incarnation, m.changedAt = swimSuspect, u.Incarnation, s.p2p.clock.Now()
+		case swimDead:
+			if m.status == swimDead || u.Incarnation < m.incarnation {
+				continue
+			}
+			m.status, m.incarnation, m.changedAt = swimDead, u.Incarnation, s.p2p.clock.Now()
+		}
+
+		changes[u.Peer.Id] = u.Status.peerStatus()
+		s.enqueue(u)
+	}
+	s.mutex.Unlock()
+
+	for _, peer := range added {
+		s.p2p.store(peer, false)
+	}
+
+	for id, status := range changes {
+		s.p2p.setStatus(id, status)
+	}
+
+	if len(changes) > 0 {
+		s.p2p.notify()
+	}
+}
+
+func (s *swim) enqueue(u *swimUpdate) {
+	for i, b := range s.queue {
+		if b.update.Peer.Id == u.Peer.Id {
+			s.queue = append(s.queue[:i], s.queue[i+1:]...)
+			break
+		}
+	}
+
+	s.queue = append(s.queue, &swimBroadcast{update: u})
+}
+
+func (s *swim) piggyback() []*swimUpdate {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	limit := s.opts.Retransmit * int(math.Ceil(math.Log10(float64(len(s.members)+2))))
+
+	sort.SliceStable(s.queue, func(i, j int) bool {
+		return s.queue[i].transmits < s.queue[j].transmits
+	})
+
+	var updates []*swimUpdate
+	kept := s.queue[:0]
+	for _, b := range s.queue {
+		if len(updates) < s.opts.MaxPiggyback {
+			updates = append(updates, b.update)
+			b.transmits++
+		}
+		if b.transmits < limit {
+			kept = append(kept, b)
+		}
+	}
+	s.queue = kept
+
+	return updates
+}
+
+func isSwimSubject(subject string) bool {
+	return strings.HasPrefix(subject, swimPrefix)
+}
@@ -0,0 +1,137 @@
+package p2p_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/yaien/p2p"
+)
+
+func swimNode(t *testing.T, name string, opts *p2p.SwimOptions) (*p2p.P2P, *httptest.Server) {
+	mx := http.NewServeMux()
+	srv := httptest.NewServer(mx)
+	p := p2p.New(p2p.Options{Addr: srv.URL, Name: name, Transport: &p2p.HttpTransport{}, Swim: opts, Evict: 300 * time.Millisecond})
+	p2p.NewHttpServer(p, nil, "").Register(mx)
+	return p, srv
+}
+
+func eventually(t *testing.T, timeout time.Duration, cond func() bool) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return true
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return cond()
+}
+
+func TestP2P_Swim_Membership(t *testing.T) {
+	opts := &p2p.SwimOptions{Interval: 50 * time.Millisecond, SuspicionTimeout: 200 * time.Millisecond}
+
+	a, srvA := swimNode(t, "a", opts)
+	defer srvA.Close()
+	b, srvB := swimNode(t, "b", opts)
+	defer srvB.Close()
+	c, srvC := swimNode(t, "c", opts)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := a.Discover(b.CurrentAddr()); err != nil {
+		t.Fatalf("failed at discover: %s", err)
+	}
+	if err := c.Discover(b.CurrentAddr()); err != nil {
+		t.Fatalf("failed at discover: %s", err)
+	}
+
+	crash, stop := context.WithCancel(ctx)
+
+	go a.Start(ctx)
+	go b.Start(ctx)
+	go c.Start(crash)
+
+	if !eventually(t, 2*time.Second, func() bool { return len(a.Peers()) == 2 }) {
+		t.Fatalf("expected a to learn about c through gossip, got %d peers", len(a.Peers()))
+	}
+
+	stop()
+	srvC.Close()
+
+	if !eventually(t, 3*time.Second, func() bool { return len(a.Peers()) == 1 && len(b.Peers()) == 1 }) {
+		t.Errorf("expected c to be removed, a has %d peers and b has %d", len(a.Peers()), len(b.Peers()))
+	}
+}
+
+func TestP2P_Swim_Incarnation(t *testing.T) {
+	opts := &p2p.SwimOptions{Interval: 50 * time.Millisecond, SuspicionTimeout: 200 * time.Millisecond}
+
+	a, srvA := swimNode(t, "a", opts)
+	defer srvA.Close()
+	b, srvB := swimNode(t, "b", opts)
+	defer srvB.Close()
+
+	incarnation := a.State().Current.Incarnation
+	if incarnation == 0 {
+		t.Fatalf("expected swim nodes to start from a non zero incarnation")
+	}
+
+	if err := a.Discover(b.CurrentAddr()); err != nil {
+		t.Fatalf("failed at discover: %s", err)
+	}
+
+	peers := b.Peers()
+	if len(peers) != 1 || peers[0].Incarnation != incarnation {
+		t.Errorf("expected b to store the incarnation announced by a, got %v", peers)
+	}
+}
+
+func TestP2P_Swim_PingReqTarget(t *testing.T) {
+	opts := &p2p.SwimOptions{Interval: time.Minute, SuspicionTimeout: time.Minute}
+
+	a, srvA := swimNode(t, "a", opts)
+	defer srvA.Close()
+	b, srvB := swimNode(t, "b", opts)
+	defer srvB.Close()
+
+	var hits int32
+	victim := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+	}))
+	defer victim.Close()
+
+	if err := b.Discover(a.CurrentAddr()); err != nil {
+		t.Fatalf("failed at discover: %s", err)
+	}
+
+	client := p2p.New(p2p.Options{Name: "client", Transport: &p2p.HttpTransport{}})
+	if err := client.Discover(a.CurrentAddr()); err != nil {
+		t.Fatalf("failed at discover: %s", err)
+	}
+
+	unknown := p2p.New(p2p.Options{Addr: victim.URL, Name: "unknown"}).State().Current
+	body, _ := json.Marshal(map[string]any{"target": unknown})
+	_, err := client.Request("a", "p2p.swim.ping-req", body)
+	var e *p2p.Error
+	if !errors.As(err, &e) || e.Code != p2p.CodeNotFound {
+		t.Errorf("expected a ping-req for an unknown target to be rejected, got %v", err)
+	}
+
+	redirected := b.State().Current
+	redirected.Addr = victim.URL
+	body, _ = json.Marshal(map[string]any{"target": redirected})
+	_, err = client.Request("a", "p2p.swim.ping-req", body)
+	if err != nil {
+		t.Errorf("expected a ping-req for a member to probe it: %s", err)
+	}
+
+	if atomic.LoadInt32(&hits) != 0 {
+		t.Errorf("expected the target records sent along ping-reqs to be ignored, the victim got %d hits", hits)
+	}
+}
@@ -0,0 +1,84 @@
+package p2p
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+)
+
+// ServerTLSConfig loads the server certificate and, when a ca is given,
+// requires clients to present a certificate signed by it (mutual TLS).
+func ServerTLSConfig(cert, key, ca string) (*tls.Config, error) {
+	pair, err := tls.LoadX509KeyPair(cert, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed loading key pair: %w", err)
+	}
+
+	cfg := &tls.Config{Certificates: []tls.Certificate{pair}, MinVersion: tls.VersionTLS12}
+	if ca == "" {
+		return cfg, nil
+	}
+
+	pool, err := loadCertPool(ca)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.ClientCAs = pool
+	cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	return cfg, nil
+}
+
+// ClientTLSConfig trusts the given ca, or the system roots when empty, and
+// presents the cert key pair when given, for mutual TLS.
+func ClientTLSConfig(cert, key, ca string) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if cert != "" {
+		pair, err := tls.LoadX509KeyPair(cert, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed loading key pair: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{pair}
+	}
+
+	if ca != "" {
+		pool, err := loadCertPool(ca)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = pool
+	}
+
+	return cfg, nil
+}
+
+// tlsListener serves l over tls when config is set, offering the protocols
+// the server speaks unless the config sets its own.
+func tlsListener(l net.Listener, config *tls.Config, protos ...string) net.Listener {
+	if config == nil {
+		return l
+	}
+
+	config = config.Clone()
+	if len(config.NextProtos) == 0 {
+		config.NextProtos = protos
+	}
+	return tls.NewListener(l, config)
+}
+
+func loadCertPool(ca string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(ca)
+	if err != nil {
+		return nil, fmt.Errorf("failed reading ca: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", ca)
+	}
+
+	return pool, nil
+}
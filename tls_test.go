@@ -0,0 +1,248 @@
+package p2p_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yaien/p2p"
+	"golang.org/x/net/nettest"
+)
+
+// certificates writes a ca and a certificate signed by it, valid for
+// localhost as both server and client, returning their paths.
+func certificates(t *testing.T) (cert, key, ca string) {
+	dir := t.TempDir()
+
+	write := func(name, kind string, der []byte) string {
+		path := filepath.Join(dir, name)
+		err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: kind, Bytes: der}), 0600)
+		if err != nil {
+			t.Fatalf("failed writing %s: %s", name, err)
+		}
+		return path
+	}
+
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "p2p-test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDer, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("failed creating ca: %s", err)
+	}
+
+	leafKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	leafTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("::1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	leafDer, err := x509.CreateCertificate(rand.Reader, leafTemplate, caTemplate, &leafKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("failed creating certificate: %s", err)
+	}
+
+	keyDer, _ := x509.MarshalECPrivateKey(leafKey)
+	return write("cert.pem", "CERTIFICATE", leafDer), write("key.pem", "EC PRIVATE KEY", keyDer), write("ca.pem", "CERTIFICATE", caDer)
+}
+
+func TestP2P_Grpc_Key(t *testing.T) {
+	lis, err := nettest.NewLocalListener("tcp")
+	if err != nil {
+		t.Fatalf("failed creating testing listener: %s", err)
+	}
+
+	to := p2p.New(p2p.Options{Addr: lis.Addr().String(), Name: "target-p2p", Transport: &p2p.GrpcTransport{Key: "secret"}})
+
+	srv := p2p.NewGrpcServer(to, nil, "secret")
+	go srv.Serve(lis)
+	defer srv.Close()
+
+	intruder := p2p.New(p2p.Options{Transport: &p2p.GrpcTransport{Key: "wrong"}})
+	err = intruder.Discover(to.CurrentAddr())
+	if err == nil {
+		t.Fatal("expected discover with a wrong key to fail")
+	}
+
+	from := p2p.New(p2p.Options{Transport: &p2p.GrpcTransport{Key: "secret"}})
+	err = from.Discover(to.CurrentAddr())
+	if err != nil {
+		t.Fatalf("failed at discover: %s", err)
+	}
+}
+
+func TestP2P_Grpc_MutualTLS(t *testing.T) {
+	cert, key, ca := certificates(t)
+
+	serverTLS, err := p2p.ServerTLSConfig(cert, key, ca)
+	if err != nil {
+		t.Fatalf("failed loading server tls: %s", err)
+	}
+
+	clientTLS, err := p2p.ClientTLSConfig(cert, key, ca)
+	if err != nil {
+		t.Fatalf("failed loading client tls: %s", err)
+	}
+
+	lis, err := nettest.NewLocalListener("tcp")
+	if err != nil {
+		t.Fatalf("failed creating testing listener: %s", err)
+	}
+
+	to := p2p.New(p2p.Options{Addr: lis.Addr().String(), Name: "target-p2p", Transport: &p2p.GrpcTransport{TLSConfig: clientTLS}})
+	to.HandleFunc(func(ctx context.Context, r *p2p.MessageRequest) ([]byte, error) {
+		return []byte(`{ "message": "received" }`), nil
+	})
+
+	srv := p2p.NewGrpcServer(to, nil, "")
+	srv.TLSConfig = serverTLS
+	go srv.Serve(lis)
+	defer srv.Close()
+
+	anonymousTLS, err := p2p.ClientTLSConfig("", "", ca)
+	if err != nil {
+		t.Fatalf("failed loading client tls: %s", err)
+	}
+
+	anonymous := p2p.New(p2p.Options{Transport: &p2p.GrpcTransport{TLSConfig: anonymousTLS}, Timeout: time.Second})
+	err = anonymous.Discover(to.CurrentAddr())
+	if err == nil {
+		t.Fatal("expected discover without a client certificate to fail")
+	}
+
+	from := p2p.New(p2p.Options{Transport: &p2p.GrpcTransport{TLSConfig: clientTLS}})
+	err = from.Discover(to.CurrentAddr())
+	if err != nil {
+		t.Fatalf("failed at discover: %s", err)
+	}
+
+	_, err = from.Request("target-p2p", "message", []byte(`{}`))
+	if err != nil {
+		t.Fatalf("failed at request: %s", err)
+	}
+}
+
+func TestMuxServer_TLS(t *testing.T) {
+	cert, key, ca := certificates(t)
+
+	serverTLS, err := p2p.ServerTLSConfig(cert, key, ca)
+	if err != nil {
+		t.Fatalf("failed loading server tls: %s", err)
+	}
+
+	clientTLS, err := p2p.ClientTLSConfig(cert, key, ca)
+	if err != nil {
+		t.Fatalf("failed loading client tls: %s", err)
+	}
+
+	lis, err := nettest.NewLocalListener("tcp")
+	if err != nil {
+		t.Fatalf("failed creating testing listener: %s", err)
+	}
+
+	to := p2p.New(p2p.Options{Addr: "https://" + lis.Addr().String(), Name: "target-p2p", Protocols: []string{p2p.ProtocolGrpc, p2p.ProtocolWebSocket, p2p.ProtocolHttp}})
+	to.HandleFunc(func(ctx context.Context, r *p2p.MessageRequest) ([]byte, error) {
+		return r.Body, nil
+	})
+
+	srv := p2p.NewMuxServer(to, nil, "")
+	srv.TLSConfig = serverTLS
+	go srv.Serve(lis)
+	defer srv.Close()
+
+	plain := p2p.New(p2p.Options{Transport: &p2p.HttpTransport{}, Timeout: time.Second})
+	err = plain.Discover("http://" + lis.Addr().String())
+	if err == nil {
+		t.Fatal("expected a plaintext discover to fail")
+	}
+
+	for _, protocol := range []string{p2p.ProtocolGrpc, p2p.ProtocolWebSocket, p2p.ProtocolHttp} {
+		transport := &p2p.MultiTransport{
+			Transports: map[string]p2p.Transport{
+				p2p.ProtocolHttp:      p2p.NewHttpTransport("", p2p.HttpOptions{TLSConfig: clientTLS}),
+				p2p.ProtocolGrpc:      &p2p.GrpcTransport{TLSConfig: clientTLS},
+				p2p.ProtocolWebSocket: &p2p.WebSocketTransport{TLSConfig: clientTLS},
+			},
+			Preference: []string{protocol},
+		}
+
+		from := p2p.New(p2p.Options{Transport: transport})
+		err = from.Discover(to.CurrentAddr())
+		if err != nil {
+			t.Fatalf("failed at discover over %s: %s", protocol, err)
+		}
+
+		reply, err := from.Request("target-p2p", "echo", []byte(`{}`))
+		if err != nil || string(reply) != `{}` {
+			t.Errorf("failed at request over %s: %s %v", protocol, reply, err)
+		}
+	}
+}
+
+func TestSubscribe_TLS(t *testing.T) {
+	cert, key, ca := certificates(t)
+	serverTLS, err := p2p.ServerTLSConfig(cert, key, ca)
+	if err != nil {
+		t.Fatalf("failed loading server tls: %s", err)
+	}
+
+	clientTLS, err := p2p.ClientTLSConfig(cert, key, ca)
+	if err != nil {
+		t.Fatalf("failed loading client tls: %s", err)
+	}
+
+	hub := p2p.New(p2p.Options{Name: "hub-p2p"})
+	sub := p2p.NewSubscriber(hub.Channel())
+
+	rest := p2p.NewHttpServer(hub, sub, "secret")
+	rest.TLSConfig = serverTLS
+	restAddr := "https://" + serveListener(t, hub, rest)
+
+	grpc := p2p.NewGrpcServer(hub, sub, "secret")
+	grpc.TLSConfig = serverTLS
+	grpcAddr := serveListener(t, hub, grpc)
+
+	for name, subscribe := range map[string]struct {
+		addr string
+		fn   p2p.MonitorSubscribeFunc
+	}{
+		"http": {restAddr, p2p.NewHttpTransport("secret", p2p.HttpOptions{TLSConfig: clientTLS}).Subscribe},
+		"grpc": {grpcAddr, (&p2p.GrpcTransport{Key: "secret", TLSConfig: clientTLS}).Subscribe},
+	} {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		out := make(chan *p2p.State, 1)
+		go subscribe.fn(ctx, subscribe.addr, out)
+
+		select {
+		case state := <-out:
+			if state.Current.Name != "hub-p2p" {
+				t.Errorf("unexpected state of %s over %s", state.Current.Name, name)
+			}
+		case <-ctx.Done():
+			t.Errorf("expected the %s subscriber to receive the state over tls", name)
+		}
+		cancel()
+	}
+}
@@ -0,0 +1,68 @@
+package p2p_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/yaien/p2p"
+	"github.com/yaien/p2p/p2ptest"
+)
+
+func benchmarkTransport(b *testing.B, f p2ptest.Factory) {
+	to := p2p.New(p2p.Options{Name: "target-p2p", Transport: f.NewTransport("")})
+	to.HandleFunc(func(ctx context.Context, r *p2p.MessageRequest) ([]byte, error) {
+		return r.Body, nil
+	})
+	f.Serve(b, to, "")
+
+	from := p2p.New(p2p.Options{Name: "from-p2p", Transport: f.NewTransport("")})
+	err := from.Discover(to.CurrentAddr())
+	if err != nil {
+		b.Fatalf("failed at discover: %s", err)
+	}
+
+	body := []byte(`{"message":"hello world","values":[1,2,3,4,5,6,7,8]}`)
+
+	b.Run("Serial", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, err := from.Request("target-p2p", "echo", body)
+			if err != nil {
+				b.Fatalf("failed at request: %s", err)
+			}
+		}
+	})
+
+	b.Run("Parallel", func(b *testing.B) {
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				_, err := from.Request("target-p2p", "echo", body)
+				if err != nil {
+					b.Errorf("failed at request: %s", err)
+					return
+				}
+			}
+		})
+	})
+}
+
+func BenchmarkHttpTransport(b *testing.B) {
+	benchmarkTransport(b, httpFactory())
+}
+
+func BenchmarkGrpcTransport(b *testing.B) {
+	benchmarkTransport(b, grpcFactory())
+}
+
+func BenchmarkWebSocketTransport(b *testing.B) {
+	benchmarkTransport(b, webSocketFactory())
+}
+
+func BenchmarkTcpTransport(b *testing.B) {
+	benchmarkTransport(b, tcpFactory())
+}
+
+func BenchmarkMemoryTransport(b *testing.B) {
+	benchmarkTransport(b, memoryFactory())
+}
@@ -2,45 +2,239 @@ package p2p
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
+	"strconv"
 	"sync"
+	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 )
 
+// GrpcTransport keeps one client connection per address, dialed the first
+// time a peer is reached, whether it was connected to or learned through
+// gossip, and closed once the peer is forgotten.
 type GrpcTransport struct {
-	clients sync.Map
+	Key       string
+	TLSConfig *tls.Config
+	mutex     sync.Mutex
+	conns     map[string]*grpc.ClientConn
+	dialed    map[string]map[string]bool
+	dials     uint64
+	evictions uint64
 }
 
-func (n *GrpcTransport) Connect(from *Peer, addr string) (*State, error) {
-	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+// GrpcStats describes the connections held by a GrpcTransport.
+type GrpcStats struct {
+	Conns     map[string]connectivity.State
+	Dials     uint64
+	Evictions uint64
+}
+
+func (n *GrpcTransport) client(addr string) (P2PClient, error) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	conn, ok := n.conns[addr]
+	if ok {
+		return NewP2PClient(conn), nil
+	}
+
+	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(n.credentials()), grpc.WithUnaryInterceptor(n.sign), grpc.WithStreamInterceptor(n.signStream))
 	if err != nil {
 		return nil, fmt.Errorf("failed creating client connection: %w", err)
 	}
 
-	client := NewP2PClient(conn)
-	res, err := client.Connect(context.TODO(), &ConnectRequest{Current: from})
+	if n.conns == nil {
+		n.conns = make(map[string]*grpc.ClientConn)
+	}
+	n.conns[addr] = conn
+	n.dials++
+	return NewP2PClient(conn), nil
+}
+
+func (n *GrpcTransport) credentials() credentials.TransportCredentials {
+	if n.TLSConfig != nil {
+		return credentials.NewTLS(n.TLSConfig)
+	}
+	return insecure.NewCredentials()
+}
+
+// track records the addr a peer was connected through, which may differ from
+// the addr it advertises, so forgetting the peer closes that conn too.
+func (n *GrpcTransport) track(id, addr string) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if n.dialed == nil {
+		n.dialed = make(map[string]map[string]bool)
+	}
+	if n.dialed[id] == nil {
+		n.dialed[id] = make(map[string]bool)
+	}
+	n.dialed[id][addr] = true
+}
+
+// Forget closes the connections to the peer, to be dialed again if the peer
+// is reached later.
+func (n *GrpcTransport) Forget(peer *Peer) {
+	addrs := map[string]bool{peer.Addr: true}
+
+	n.mutex.Lock()
+	for addr := range n.dialed[peer.Id] {
+		addrs[addr] = true
+	}
+	delete(n.dialed, peer.Id)
+
+	var conns []*grpc.ClientConn
+	for addr := range addrs {
+		conn, ok := n.conns[addr]
+		if ok {
+			delete(n.conns, addr)
+			conns = append(conns, conn)
+			n.evictions++
+		}
+	}
+	n.mutex.Unlock()
+
+	for _, conn := range conns {
+		conn.Close()
+	}
+}
+
+// Stats returns the state of every pooled connection along with how many
+// were dialed and evicted so far.
+func (n *GrpcTransport) Stats() GrpcStats {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	stats := GrpcStats{Conns: make(map[string]connectivity.State, len(n.conns)), Dials: n.dials, Evictions: n.evictions}
+	for addr, conn := range n.conns {
+		stats.Conns[addr] = conn.GetState()
+	}
+	return stats
+}
+
+// Close closes every pooled connection.
+func (n *GrpcTransport) Close() error {
+	n.mutex.Lock()
+	conns := n.conns
+	n.conns, n.dialed = nil, nil
+	n.mutex.Unlock()
+
+	for _, conn := range conns {
+		conn.Close()
+	}
+	return nil
+}
+
+// sign adds the timestamp, nonce and HMAC signature of the request to the
+// outgoing metadata.
+func (n *GrpcTransport) sign(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	data, err := signedBytes(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed at p2p connection: %w", err)
+		return err
 	}
 
-	n.clients.Store(res.State.Current.Id, client)
+	return invoker(signContext(ctx, n.Key, method, data), method, req, reply, cc, opts...)
+}
 
-	return res.State, nil
+// signStream signs the opening of a stream, whose messages aren't known yet,
+// as a call with an empty body.
+func (n *GrpcTransport) signStream(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return streamer(signContext(ctx, n.Key, method, nil), desc, cc, method, opts...)
 }
 
-func (n *GrpcTransport) Send(from, to *Peer, subject string, body []byte) ([]byte, error) {
-	v, ok := n.clients.Load(to.Id)
+// signContext appends the timestamp, nonce and HMAC signature of a call to
+// the outgoing metadata.
+func signContext(ctx context.Context, key, method string, data []byte) context.Context {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonce := newNonce()
+	return metadata.AppendToOutgoingContext(ctx,
+		"x-timestamp", timestamp,
+		"x-nonce", nonce,
+		"x-signature", sign(key, "POST", method, timestamp, nonce, data),
+	)
+}
+
+func signedBytes(v any) ([]byte, error) {
+	m, ok := v.(proto.Message)
 	if !ok {
-		return nil, fmt.Errorf("missing client for peer id %s", to.Id)
+		return nil, fmt.Errorf("unexpected message type %T", v)
+	}
+
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("failed encoding message: %w", err)
+	}
+
+	return data, nil
+}
+
+func (n *GrpcTransport) Connect(ctx context.Context, from *Peer, addr string) (*State, error) {
+	client, err := n.client(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := client.Connect(ctx, &ConnectRequest{Current: from})
+	if err != nil {
+		return nil, fmt.Errorf("failed at p2p connection: %w", err)
+	}
+
+	n.track(res.State.GetCurrent().GetId(), addr)
+	return res.State, nil
+}
+
+func (n *GrpcTransport) Send(ctx context.Context, from, to *Peer, subject string, body []byte) ([]byte, error) {
+	client, err := n.client(to.Addr)
+	if err != nil {
+		return nil, err
 	}
 
-	client := v.(P2PClient)
-	res, err := client.Message(context.TODO(), &MessageRequest{From: from, Subject: subject, Body: body})
+	res, err := client.Message(ctx, &MessageRequest{From: from, Subject: subject, Body: body, ContentType: contentType(ctx), Headers: requestHeaders(ctx)})
 	if err != nil {
-		return nil, fmt.Errorf("failed at sensing message: %w", err)
+		return nil, fmt.Errorf("failed at sensing message: %w", replyError(err))
 	}
 
+	setReplyHeaders(ctx, res.Headers)
 	return res.Body, nil
 }
+
+func (n *GrpcTransport) Leave(ctx context.Context, from, to *Peer) error {
+	client, err := n.client(to.Addr)
+	if err != nil {
+		return err
+	}
+	defer n.Forget(to)
+
+	_, err = client.Leave(ctx, &LeaveRequest{Current: from})
+	if err != nil {
+		return fmt.Errorf("failed at leaving: %w", err)
+	}
+
+	return nil
+}
+
+// replyError rebuilds the error replied by the handler from the status
+// details, returning other errors as they are.
+func replyError(err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+
+	for _, detail := range st.Details() {
+		s, ok := detail.(*ErrorStatus)
+		if ok {
+			return s.err()
+		}
+	}
+	return err
+}
@@ -0,0 +1,134 @@
+package p2p_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/yaien/p2p"
+	"golang.org/x/net/nettest"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestGrpcTransport_Pool(t *testing.T) {
+	echo := func(ctx context.Context, r *p2p.MessageRequest) ([]byte, error) {
+		return r.Body, nil
+	}
+
+	hub := p2p.New(p2p.Options{Name: "hub-p2p", Transport: &p2p.GrpcTransport{}})
+	serveListener(t, hub, p2p.NewGrpcServer(hub, nil, ""))
+
+	gossiped := p2p.New(p2p.Options{Name: "gossiped-p2p", Transport: &p2p.GrpcTransport{}})
+	gossiped.HandleFunc(echo)
+	serveListener(t, gossiped, p2p.NewGrpcServer(gossiped, nil, ""))
+
+	err := gossiped.Discover(hub.CurrentAddr())
+	if err != nil {
+		t.Fatalf("failed at discover: %s", err)
+	}
+
+	transport := &p2p.GrpcTransport{}
+	defer transport.Close()
+	client := p2p.New(p2p.Options{Name: "client-p2p", Transport: transport})
+
+	err = client.Discover(hub.CurrentAddr())
+	if err != nil {
+		t.Fatalf("failed at discover: %s", err)
+	}
+
+	stats := transport.Stats()
+	if stats.Dials != 1 || len(stats.Conns) != 1 {
+		t.Fatalf("expected a single conn to the hub after discover, got %+v", stats)
+	}
+
+	_, err = client.Request("gossiped-p2p", "echo", []byte(`{}`))
+	if err != nil {
+		t.Fatalf("failed at request to a gossiped peer: %s", err)
+	}
+
+	stats = transport.Stats()
+	if stats.Dials != 2 || len(stats.Conns) != 2 {
+		t.Fatalf("expected a lazy conn to the gossiped peer, got %+v", stats)
+	}
+
+	for i := 0; i < 3; i++ {
+		client.Scan(context.Background())
+	}
+
+	stats = transport.Stats()
+	if stats.Dials != 2 || len(stats.Conns) != 2 {
+		t.Fatalf("expected scans to reuse conns, got %+v", stats)
+	}
+
+	for _, peer := range client.Peers() {
+		if peer.Name == "gossiped-p2p" {
+			client.Remove(peer)
+		}
+	}
+
+	stats = transport.Stats()
+	_, ok := stats.Conns[gossiped.CurrentAddr()]
+	if ok || stats.Evictions != 1 || len(stats.Conns) != 1 {
+		t.Errorf("expected the gossiped peer conn to be evicted, got %+v", stats)
+	}
+}
+
+func TestGrpcServer_Signature_State(t *testing.T) {
+	hub := p2p.New(p2p.Options{Name: "hub-p2p", Transport: &p2p.GrpcTransport{Key: "secret"}})
+	serveListener(t, hub, p2p.NewGrpcServer(hub, p2p.NewSubscriber(hub.Channel()), "secret"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	err := p2p.Subscribe2Grpc(ctx, hub.CurrentAddr(), make(chan *p2p.State, 1))
+	if status.Code(errors.Unwrap(err)) != codes.Unauthenticated {
+		t.Fatalf("expected an unsigned state stream to be unauthenticated, got %v", err)
+	}
+
+	out := make(chan *p2p.State, 1)
+	go p2p.GrpcSubscriber("secret")(ctx, hub.CurrentAddr(), out)
+
+	select {
+	case state := <-out:
+		if state.Current.Name != "hub-p2p" {
+			t.Errorf("unexpected state of %s", state.Current.Name)
+		}
+	case <-ctx.Done():
+		t.Fatal("expected a signed state stream to receive the state")
+	}
+}
+
+func TestGrpcTransport_ForgetConnected(t *testing.T) {
+	lis, err := nettest.NewLocalListener("tcp")
+	if err != nil {
+		t.Fatalf("failed creating testing listener: %s", err)
+	}
+
+	_, port, _ := net.SplitHostPort(lis.Addr().String())
+	e, _ := p2p.ParseEndpoint("grpc://" + lis.Addr().String())
+	hub := p2p.New(p2p.Options{Addr: "localhost:" + port, Name: "hub-p2p", Endpoints: []*p2p.Endpoint{e}, Transport: &p2p.GrpcTransport{}})
+	srv := p2p.NewGrpcServer(hub, nil, "")
+	go srv.Serve(lis)
+	defer srv.Close()
+
+	transport := &p2p.GrpcTransport{}
+	defer transport.Close()
+	client := p2p.New(p2p.Options{Name: "client-p2p", Transport: transport})
+
+	err = client.Discover(lis.Addr().String())
+	if err != nil {
+		t.Fatalf("failed at discover: %s", err)
+	}
+
+	for _, peer := range client.Peers() {
+		client.Remove(peer)
+	}
+
+	stats := transport.Stats()
+	if len(stats.Conns) != 0 || stats.Evictions != 1 {
+		t.Errorf("expected the conn dialed at connect to be evicted, got %+v", stats)
+	}
+}
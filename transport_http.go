@@ -2,57 +2,195 @@ package p2p
 
 import (
 	"bytes"
-	"crypto/sha256"
+	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// HttpMessage carries json bodies as is in Body, so older nodes keep reading
+// them, and any other body base64 encoded in Data.
 type HttpMessage struct {
-	From    *Peer           `json:"from"`
-	Subject string          `json:"subject"`
-	Body    json.RawMessage `json:"body"`
+	From        *Peer             `json:"from"`
+	Subject     string            `json:"subject"`
+	Body        json.RawMessage   `json:"body,omitempty"`
+	Data        []byte            `json:"data,omitempty"`
+	ContentType string            `json:"content_type,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
 }
 
 type HttpMessageReply struct {
-	Body  json.RawMessage `json:"body"`
-	Error string          `json:"error"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+	Data    []byte            `json:"data,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Error   string            `json:"error"`
+	Code    string            `json:"code,omitempty"`
+	Details map[string]string `json:"details,omitempty"`
 }
 
+// splitBody returns body as the json or the base64 data field, json bodies
+// are only carried as is when encoding leaves them byte identical, so the
+// receiver gets and verifies the exact bytes sent.
+func splitBody(body []byte) (json.RawMessage, []byte) {
+	if len(body) == 0 || !json.Valid(body) {
+		return nil, body
+	}
+
+	encoded, err := json.Marshal(json.RawMessage(body))
+	if err != nil || !bytes.Equal(encoded, body) {
+		return nil, body
+	}
+
+	return body, nil
+}
+
+// joinBody returns the body carried by either field.
+func joinBody(body json.RawMessage, data []byte) []byte {
+	if len(data) > 0 {
+		return data
+	}
+	return body
+}
+
+// HttpTransport sends requests with Client, or http.DefaultClient when nil.
+// Unix socket addresses always use a client dialing the socket.
 type HttpTransport struct {
-	Key string
+	Key    string
+	Client *http.Client
 }
 
-func signature(p *Peer, key string) string {
-	source := []byte(p.Id + p.Name + p.Addr + key)
-	return fmt.Sprintf("%x", sha256.Sum256(source))
+// HttpOptions tunes the client built by NewHttpTransport, zero values keep
+// the net/http defaults.
+type HttpOptions struct {
+	Timeout             time.Duration
+	IdleConnTimeout     time.Duration
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	TLSConfig           *tls.Config
+	Proxy               func(*http.Request) (*url.URL, error)
 }
 
-func (n *HttpTransport) Connect(from *Peer, addr string) (*State, error) {
-	var buff bytes.Buffer
-	err := json.NewEncoder(&buff).Encode(from)
+func NewHttpTransport(key string, opts HttpOptions) *HttpTransport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if opts.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = opts.IdleConnTimeout
+	}
+	if opts.MaxIdleConns > 0 {
+		transport.MaxIdleConns = opts.MaxIdleConns
+	}
+	if opts.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = opts.MaxIdleConnsPerHost
+	}
+	if opts.TLSConfig != nil {
+		transport.TLSClientConfig = opts.TLSConfig
+	}
+	if opts.Proxy != nil {
+		transport.Proxy = opts.Proxy
+	}
+
+	return &HttpTransport{Key: key, Client: &http.Client{Transport: transport, Timeout: opts.Timeout}}
+}
+
+// target returns the client and base url to reach addr with.
+func (n *HttpTransport) target(addr string) (*http.Client, string) {
+	client, base := httpTarget(addr)
+	if n.Client != nil && !strings.HasPrefix(addr, unixScheme) {
+		client = n.Client
+	}
+	return client, base
+}
+
+// doRequest sends the request, returning the response only when its status is OK.
+func doRequest(client *http.Client, req *http.Request) (*http.Response, error) {
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed doing request: %w", err)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		defer res.Body.Close()
+		return nil, statusError(res)
+	}
+
+	return res, nil
+}
+
+// closeBody drains what is left of the body so the connection is reused.
+func closeBody(res *http.Response) {
+	io.Copy(io.Discard, io.LimitReader(res.Body, maxErrorBody))
+	res.Body.Close()
+}
+
+// sign sets the timestamp, nonce and HMAC signature headers of a request
+// carrying the given body.
+func (n *HttpTransport) sign(req *http.Request, body []byte) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonce := newNonce()
+	req.Header.Set("X-Timestamp", timestamp)
+	req.Header.Set("X-Nonce", nonce)
+	req.Header.Set("X-Signature", sign(n.Key, req.Method, req.URL.Path, timestamp, nonce, body))
+}
+
+// setTimeout forwards the time left before the request deadline, so the
+// remote handler context expires along with the caller's.
+func setTimeout(req *http.Request) {
+	deadline, ok := req.Context().Deadline()
+	if ok {
+		req.Header.Set("X-Timeout", time.Until(deadline).String())
+	}
+}
+
+const maxErrorBody = 4 << 10
+
+// statusError reads the error replied along a non OK status, falling back to
+// the raw body when it isn't a json reply, as proxies and load balancers do.
+func statusError(res *http.Response) error {
+	data, _ := io.ReadAll(io.LimitReader(res.Body, maxErrorBody))
+
+	var r HttpMessageReply
+	err := json.Unmarshal(data, &r)
+	if err != nil {
+		r.Error = strings.TrimSpace(string(data))
+	}
+
+	if r.Error == "" {
+		r.Error = http.StatusText(res.StatusCode)
+	}
+
+	if r.Code != "" {
+		return fmt.Errorf("req to %s failed with status %d: %w", res.Request.URL, res.StatusCode, &Error{Code: Code(r.Code), Message: r.Error, Details: r.Details})
+	}
+
+	return fmt.Errorf("req to %s failed with status %d: %s", res.Request.URL, res.StatusCode, r.Error)
+}
+
+func (n *HttpTransport) Connect(ctx context.Context, from *Peer, addr string) (*State, error) {
+	data, err := json.Marshal(from)
 	if err != nil {
 		return nil, fmt.Errorf("failed encoding current: %w", err)
 	}
 
-	url := fmt.Sprintf("%s/p2p/connect", addr)
-	req, err := http.NewRequest("POST", url, &buff)
+	client, base := n.target(addr)
+	req, err := http.NewRequestWithContext(ctx, "POST", base+"/p2p/connect", bytes.NewReader(data))
 	if err != nil {
 		return nil, fmt.Errorf("failed creating request: %w", err)
 	}
 
-	req.Header.Set("X-Signature", signature(from, n.Key))
+	n.sign(req, data)
 	req.Header.Set("Content-Type", "application/json")
+	setTimeout(req)
 
-	var h http.Client
-	res, err := h.Do(req)
+	res, err := doRequest(client, req)
 	if err != nil {
-		return nil, fmt.Errorf("failed at post request: %w", err)
-	}
-
-	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("req to %s failed with status %d", req.URL, res.StatusCode)
+		return nil, err
 	}
+	defer closeBody(res)
 
 	var state State
 	err = json.NewDecoder(res.Body).Decode(&state)
@@ -63,26 +201,30 @@ func (n *HttpTransport) Connect(from *Peer, addr string) (*State, error) {
 	return &state, nil
 }
 
-func (n *HttpTransport) Send(from, to *Peer, subject string, body []byte) ([]byte, error) {
-	var buff bytes.Buffer
-	err := json.NewEncoder(&buff).Encode(&HttpMessage{From: from, Subject: subject, Body: body})
+func (n *HttpTransport) Send(ctx context.Context, from, to *Peer, subject string, body []byte) ([]byte, error) {
+	m := &HttpMessage{From: from, Subject: subject, ContentType: contentType(ctx), Headers: requestHeaders(ctx)}
+	m.Body, m.Data = splitBody(body)
+
+	data, err := json.Marshal(m)
 	if err != nil {
 		return nil, fmt.Errorf("failed encoding message: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", to.Addr+"/p2p/message", &buff)
+	client, base := n.target(to.Addr)
+	req, err := http.NewRequestWithContext(ctx, "POST", base+"/p2p/message", bytes.NewReader(data))
 	if err != nil {
 		return nil, fmt.Errorf("failed creating request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-Signature", signature(from, n.Key))
+	n.sign(req, data)
+	setTimeout(req)
 
-	var h http.Client
-	res, err := h.Do(req)
+	res, err := doRequest(client, req)
 	if err != nil {
-		return nil, fmt.Errorf("failed doing request: %w", err)
+		return nil, err
 	}
+	defer closeBody(res)
 
 	var r HttpMessageReply
 	err = json.NewDecoder(res.Body).Decode(&r)
@@ -90,13 +232,35 @@ func (n *HttpTransport) Send(from, to *Peer, subject string, body []byte) ([]byt
 		return nil, fmt.Errorf("failed at decoding response: %w", err)
 	}
 
-	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("request failed with status %d: %s", res.StatusCode, r.Error)
-	}
-
 	if r.Error != "" {
 		return nil, fmt.Errorf("reply error: %s", r.Error)
 	}
 
-	return r.Body, nil
+	setReplyHeaders(ctx, r.Headers)
+	return joinBody(r.Body, r.Data), nil
+}
+
+func (n *HttpTransport) Leave(ctx context.Context, from, to *Peer) error {
+	data, err := json.Marshal(from)
+	if err != nil {
+		return fmt.Errorf("failed encoding current: %w", err)
+	}
+
+	client, base := n.target(to.Addr)
+	req, err := http.NewRequestWithContext(ctx, "POST", base+"/p2p/leave", bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed creating request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	n.sign(req, data)
+	setTimeout(req)
+
+	res, err := doRequest(client, req)
+	if err != nil {
+		return err
+	}
+
+	closeBody(res)
+	return nil
 }
@@ -0,0 +1,105 @@
+package p2p_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/yaien/p2p"
+)
+
+type countingTransport struct {
+	requests atomic.Int64
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.requests.Add(1)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestHttpTransport_Client(t *testing.T) {
+	counter := &countingTransport{}
+	transport := &p2p.HttpTransport{Client: &http.Client{Transport: counter}}
+
+	server := p2p.New(p2p.Options{Name: "server-p2p", Transport: &p2p.HttpTransport{}})
+	server.HandleFunc(func(ctx context.Context, r *p2p.MessageRequest) ([]byte, error) {
+		return r.Body, nil
+	})
+	addr := httpFactory().Serve(t, server, "")
+
+	client := p2p.New(p2p.Options{Name: "client-p2p", Transport: transport})
+	err := client.Discover(addr)
+	if err != nil {
+		t.Fatalf("failed at discover: %s", err)
+	}
+
+	_, err = client.Request("server-p2p", "echo", []byte(`{}`))
+	if err != nil {
+		t.Fatalf("failed at request: %s", err)
+	}
+
+	if n := counter.requests.Load(); n != 2 {
+		t.Errorf("expected both requests through the client, got %d", n)
+	}
+}
+
+func TestHttpTransport_Timeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	transport := p2p.NewHttpTransport("", p2p.HttpOptions{Timeout: 50 * time.Millisecond, MaxIdleConnsPerHost: 4})
+	_, err := transport.Connect(context.Background(), &p2p.Peer{}, srv.URL)
+	if err == nil {
+		t.Fatal("expected the client timeout to fail the request")
+	}
+}
+
+func TestHttpTransport_NonJsonError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "<html>bad gateway</html>", http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	transport := &p2p.HttpTransport{}
+	peer := &p2p.Peer{Addr: srv.URL}
+
+	_, err := transport.Send(context.Background(), &p2p.Peer{}, peer, "echo", []byte(`{}`))
+	if err == nil || !strings.Contains(err.Error(), "status 502") || !strings.Contains(err.Error(), "bad gateway") {
+		t.Errorf("expected send to report the status and body, got %v", err)
+	}
+
+	_, err = transport.Connect(context.Background(), &p2p.Peer{}, srv.URL)
+	if err == nil || !strings.Contains(err.Error(), "status 502") {
+		t.Errorf("expected connect to report the status, got %v", err)
+	}
+}
+
+func TestHttpTransport_JsonWireFormat(t *testing.T) {
+	var received map[string]json.RawMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&received)
+		w.Write([]byte(`{"body":{"message":"received"}}`))
+	}))
+	defer srv.Close()
+
+	transport := &p2p.HttpTransport{}
+	reply, err := transport.Send(context.Background(), &p2p.Peer{}, &p2p.Peer{Addr: srv.URL}, "echo", []byte(`{"message":"hello"}`))
+	if err != nil {
+		t.Fatalf("failed at send: %s", err)
+	}
+
+	if string(received["body"]) != `{"message":"hello"}` || received["data"] != nil {
+		t.Errorf("expected json bodies to travel as json, got %s", received)
+	}
+
+	if string(reply) != `{"message":"received"}` {
+		t.Errorf("expected json replies to be read, got %s", reply)
+	}
+}
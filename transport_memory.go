@@ -0,0 +1,244 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	ErrUnreachable = errors.New("peer unreachable")
+	ErrMessageLost = errors.New("message lost")
+)
+
+type NetworkOptions struct {
+	Clock   Clock
+	Seed    int64
+	Latency time.Duration
+	Jitter  time.Duration
+	Loss    float64
+}
+
+// Network is an in-process network shared by memory transports, nodes
+// attached to it are addressed by their current addr and can be delayed,
+// dropped, partitioned and crashed from code.
+type Network struct {
+	mutex      sync.Mutex
+	clock      Clock
+	rand       *rand.Rand
+	latency    time.Duration
+	jitter     time.Duration
+	loss       float64
+	nodes      map[string]*memoryNode
+	partitions map[string]int
+}
+
+type memoryNode struct {
+	p2p     *P2P
+	key     string
+	crashed bool
+}
+
+func NewNetwork(opts NetworkOptions) *Network {
+	if opts.Clock == nil {
+		opts.Clock = systemClock{}
+	}
+
+	return &Network{
+		clock:      opts.Clock,
+		rand:       rand.New(rand.NewSource(opts.Seed)),
+		latency:    opts.Latency,
+		jitter:     opts.Jitter,
+		loss:       opts.Loss,
+		nodes:      make(map[string]*memoryNode),
+		partitions: make(map[string]int),
+	}
+}
+
+// Attach serves p on the network at its current addr, only accepting
+// transports with the same key.
+func (n *Network) Attach(p *P2P, key string) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.nodes[p.CurrentAddr()] = &memoryNode{p2p: p, key: key}
+}
+
+func (n *Network) Detach(addr string) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	delete(n.nodes, addr)
+}
+
+// Crash makes the node at addr unreachable, and unable to reach others, until
+// restarted.
+func (n *Network) Crash(addr string) {
+	n.setCrashed(addr, true)
+}
+
+func (n *Network) Restart(addr string) {
+	n.setCrashed(addr, false)
+}
+
+func (n *Network) setCrashed(addr string, crashed bool) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	node, ok := n.nodes[addr]
+	if ok {
+		node.crashed = crashed
+	}
+}
+
+// Partition splits the network in the given groups of addrs, nodes in
+// different groups can't reach each other, nodes left out of every group stay
+// reachable from all of them.
+func (n *Network) Partition(groups ...[]string) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	n.partitions = make(map[string]int)
+	for i, group := range groups {
+		for _, addr := range group {
+			n.partitions[addr] = i + 1
+		}
+	}
+}
+
+func (n *Network) Heal() {
+	n.Partition()
+}
+
+func (n *Network) SetLatency(latency, jitter time.Duration) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.latency, n.jitter = latency, jitter
+}
+
+// SetLoss sets the probability, between 0 and 1, of a message being dropped.
+func (n *Network) SetLoss(loss float64) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.loss = loss
+}
+
+// route returns the node a message from one addr to another is delivered to,
+// after waiting the network latency.
+func (n *Network) route(ctx context.Context, from, to, key string) (*P2P, error) {
+	n.mutex.Lock()
+	sender, ok := n.nodes[from]
+	if ok && sender.crashed {
+		n.mutex.Unlock()
+		return nil, fmt.Errorf("%w: %s is down", ErrUnreachable, from)
+	}
+
+	node, ok := n.nodes[to]
+	if !ok || node.crashed {
+		n.mutex.Unlock()
+		return nil, fmt.Errorf("%w: %s", ErrUnreachable, to)
+	}
+
+	a, b := n.partitions[from], n.partitions[to]
+	if a != 0 && b != 0 && a != b {
+		n.mutex.Unlock()
+		return nil, fmt.Errorf("%w: %s is partitioned from %s", ErrUnreachable, to, from)
+	}
+
+	if node.key != key {
+		n.mutex.Unlock()
+		return nil, fmt.Errorf("%w: invalid key", ErrInvalidSignature)
+	}
+
+	lost := n.loss > 0 && n.rand.Float64() < n.loss
+	delay := n.delay()
+	n.mutex.Unlock()
+
+	err := n.clock.Sleep(ctx, delay)
+	if err != nil {
+		return nil, err
+	}
+
+	if lost {
+		return nil, fmt.Errorf("%w: to %s", ErrMessageLost, to)
+	}
+
+	return node.p2p, nil
+}
+
+// reply waits the latency of a response travelling back.
+func (n *Network) reply(ctx context.Context) error {
+	n.mutex.Lock()
+	delay := n.delay()
+	n.mutex.Unlock()
+	return n.clock.Sleep(ctx, delay)
+}
+
+func (n *Network) delay() time.Duration {
+	if n.jitter <= 0 {
+		return n.latency
+	}
+	return n.latency + time.Duration(n.rand.Int63n(int64(n.jitter)))
+}
+
+// MemoryTransport delivers messages through a Network without leaving the
+// process, peers are copied on the way as if they were serialized.
+type MemoryTransport struct {
+	Network *Network
+	Key     string
+}
+
+func (m *MemoryTransport) Connect(ctx context.Context, from *Peer, addr string) (*State, error) {
+	to, err := m.Network.route(ctx, from.Addr, addr, m.Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed routing connect: %w", err)
+	}
+
+	state, err := to.Accept(proto.Clone(from).(*Peer))
+	if err != nil {
+		return nil, err
+	}
+
+	state = proto.Clone(state).(*State)
+	return state, m.Network.reply(ctx)
+}
+
+func (m *MemoryTransport) Send(ctx context.Context, from, to *Peer, subject string, body []byte) ([]byte, error) {
+	node, err := m.Network.route(ctx, from.Addr, to.Addr, m.Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed routing message: %w", err)
+	}
+
+	r := &MessageRequest{From: proto.Clone(from).(*Peer), Subject: subject, Body: append([]byte(nil), body...), ContentType: contentType(ctx), Headers: copyHeaders(requestHeaders(ctx))}
+	reply, err := node.serveMessage(ctx, r)
+	if err != nil {
+		return nil, toError(err).status().err()
+	}
+
+	setReplyHeaders(ctx, copyHeaders(reply.Headers))
+	return append([]byte(nil), reply.Body...), m.Network.reply(ctx)
+}
+
+func (m *MemoryTransport) Leave(ctx context.Context, from, to *Peer) error {
+	node, err := m.Network.route(ctx, from.Addr, to.Addr, m.Key)
+	if err != nil {
+		return fmt.Errorf("failed routing leave: %w", err)
+	}
+
+	return node.Depart(proto.Clone(from).(*Peer))
+}
+
+func copyHeaders(headers map[string]string) map[string]string {
+	if headers == nil {
+		return nil
+	}
+
+	c := make(map[string]string, len(headers))
+	for k, v := range headers {
+		c[k] = v
+	}
+	return c
+}
@@ -0,0 +1,288 @@
+package p2p_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/yaien/p2p"
+)
+
+func memoryNodes(network *p2p.Network, clock p2p.Clock, n int, opts p2p.Options) []*p2p.P2P {
+	nodes := make([]*p2p.P2P, n)
+	for i := range nodes {
+		o := opts
+		o.Name = fmt.Sprintf("node-%d", i)
+		o.Addr = fmt.Sprintf("mem://node-%d", i)
+		o.Transport = &p2p.MemoryTransport{Network: network}
+		o.Clock = clock
+		nodes[i] = p2p.New(o)
+		network.Attach(nodes[i], "")
+	}
+	return nodes
+}
+
+func TestMemoryTransport_Mesh(t *testing.T) {
+	clock := p2p.NewManualClock(time.Now())
+	network := p2p.NewNetwork(p2p.NetworkOptions{Clock: clock, Seed: 1})
+	nodes := memoryNodes(network, clock, 40, p2p.Options{Lookup: []string{"mem://node-0"}})
+
+	ctx := context.Background()
+	for round := 0; round < 2; round++ {
+		for _, node := range nodes {
+			node.Scan(ctx)
+		}
+	}
+
+	for _, node := range nodes {
+		if len(node.Peers()) != len(nodes)-1 {
+			t.Fatalf("expected %s to know %d peers, got %d", node.State().Current.Name, len(nodes)-1, len(node.Peers()))
+		}
+	}
+
+	received := make(chan string, len(nodes))
+	for _, node := range nodes {
+		node := node
+		node.HandleFunc(func(ctx context.Context, r *p2p.MessageRequest) ([]byte, error) {
+			received <- node.State().Current.Name
+			return []byte(`"pong"`), nil
+		})
+	}
+
+	err := nodes[0].Broadcast("node-*", "ping", []byte(`"ping"`))
+	if err != nil {
+		t.Fatalf("failed at broadcast: %s", err)
+	}
+
+	if len(received) != len(nodes)-1 {
+		t.Errorf("expected broadcast to reach %d peers, got %d", len(nodes)-1, len(received))
+	}
+
+	network.SetLatency(50*time.Millisecond, 0)
+	start := clock.Now()
+
+	var reply []byte
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		reply, err = nodes[0].Request("node-21", "ping", []byte(`"ping"`))
+	}()
+
+	// the request and its reply each wait for the clock to move the latency
+	for leg := 0; leg < 2; leg++ {
+		clock.BlockUntil(1)
+		select {
+		case <-done:
+			t.Fatalf("expected the request to wait for the clock, it finished after %d legs", leg)
+		default:
+		}
+		clock.Advance(50 * time.Millisecond)
+	}
+
+	<-done
+	if err != nil {
+		t.Fatalf("failed at request: %s", err)
+	}
+
+	if string(reply) != `"pong"` {
+		t.Errorf("unexpected reply %s", reply)
+	}
+
+	if elapsed := clock.Now().Sub(start); elapsed != 100*time.Millisecond {
+		t.Errorf("expected the round trip to take 100ms of simulated time, took %s", elapsed)
+	}
+}
+
+func TestMemoryTransport_Failures(t *testing.T) {
+	clock := p2p.NewManualClock(time.Now())
+	network := p2p.NewNetwork(p2p.NetworkOptions{Clock: clock, Seed: 1})
+	nodes := memoryNodes(network, clock, 3, p2p.Options{
+		Lookup:   []string{"mem://node-0"},
+		Detector: p2p.NewMissCountDetector(1, 2),
+		Evict:    time.Minute,
+	})
+
+	ctx := context.Background()
+	for round := 0; round < 2; round++ {
+		for _, node := range nodes {
+			node.Scan(ctx)
+		}
+	}
+
+	a, c := nodes[0], nodes[2]
+	for _, node := range nodes {
+		node.HandleFunc(func(ctx context.Context, r *p2p.MessageRequest) ([]byte, error) {
+			return []byte(`{}`), nil
+		})
+	}
+
+	network.Partition([]string{"mem://node-0", "mem://node-1"}, []string{"mem://node-2"})
+	_, err := a.Request("node-2", "ping", []byte(`{}`))
+	if !errors.Is(err, p2p.ErrUnreachable) {
+		t.Errorf("expected partitioned request to be unreachable, got %v", err)
+	}
+
+	network.Heal()
+	network.SetLoss(1)
+	_, err = a.Request("node-2", "ping", []byte(`{}`))
+	if !errors.Is(err, p2p.ErrMessageLost) {
+		t.Errorf("expected request to be lost, got %v", err)
+	}
+
+	network.SetLoss(0)
+	network.Crash(c.CurrentAddr())
+	a.Scan(ctx)
+	a.Scan(ctx)
+
+	status := func() p2p.PeerStatus {
+		for _, peer := range a.Peers() {
+			if peer.Id == c.State().Current.Id {
+				return peer.Status
+			}
+		}
+		return -1
+	}
+
+	if status() != p2p.PeerStatus_DEAD {
+		t.Fatalf("expected crashed peer to be dead, got %s", status())
+	}
+
+	clock.Advance(2 * time.Minute)
+	a.Scan(ctx)
+	if status() != -1 {
+		t.Errorf("expected dead peer to be evicted, got %s", status())
+	}
+}
+
+func TestMemoryTransport_Evict(t *testing.T) {
+	clock := p2p.NewManualClock(time.Now())
+	network := p2p.NewNetwork(p2p.NetworkOptions{Clock: clock, Seed: 1})
+	nodes := memoryNodes(network, clock, 3, p2p.Options{
+		Lookup:   []string{"mem://node-0"},
+		Detector: p2p.NewMissCountDetector(1, 2),
+		Evict:    time.Minute,
+	})
+
+	ctx := context.Background()
+	for round := 0; round < 2; round++ {
+		for _, node := range nodes {
+			node.Scan(ctx)
+		}
+	}
+
+	a, c := nodes[0], nodes[2]
+	status := func() p2p.PeerStatus {
+		for _, peer := range a.Peers() {
+			if peer.Id == c.State().Current.Id {
+				return peer.Status
+			}
+		}
+		return -1
+	}
+
+	network.Crash(c.CurrentAddr())
+	clock.Advance(50 * time.Second)
+	a.Scan(ctx)
+	clock.Advance(50 * time.Second)
+	a.Scan(ctx)
+
+	if status() != p2p.PeerStatus_DEAD {
+		t.Fatalf("expected peer silent for longer than evict to be dead, not evicted, got %s", status())
+	}
+
+	clock.Advance(2 * time.Minute)
+	a.Scan(ctx)
+	if status() != -1 {
+		t.Fatalf("expected dead peer to be evicted, got %s", status())
+	}
+
+	a.Scan(ctx)
+	if status() != -1 {
+		t.Fatalf("expected evicted peer not to be brought back by gossip, got %s", status())
+	}
+
+	network.Restart(c.CurrentAddr())
+	c.Scan(ctx)
+	if status() != p2p.PeerStatus_ALIVE {
+		t.Errorf("expected evicted peer to come back once heard from, got %s", status())
+	}
+}
+
+func TestMemoryTransport_GossipedUnreachable(t *testing.T) {
+	clock := p2p.NewManualClock(time.Now())
+	network := p2p.NewNetwork(p2p.NetworkOptions{Clock: clock, Seed: 1})
+	nodes := memoryNodes(network, clock, 3, p2p.Options{
+		Lookup: []string{"mem://node-1"},
+		Evict:  time.Hour,
+	})
+
+	ctx := context.Background()
+	a, c := nodes[0], nodes[2]
+	c.Scan(ctx)
+	network.Crash(c.CurrentAddr())
+	a.Scan(ctx)
+
+	status := func() p2p.PeerStatus {
+		for _, peer := range a.Peers() {
+			if peer.Id == c.State().Current.Id {
+				return peer.Status
+			}
+		}
+		return -1
+	}
+
+	if status() != p2p.PeerStatus_ALIVE {
+		t.Fatalf("expected a to learn about c through gossip, got %s", status())
+	}
+
+	for i := 0; i < 10; i++ {
+		clock.Advance(5 * time.Second)
+		a.Scan(ctx)
+	}
+
+	if status() != p2p.PeerStatus_DEAD {
+		t.Errorf("expected a gossiped peer never reached to be dead, got %s", status())
+	}
+}
+
+func TestMemoryTransport_Start_ManualClock(t *testing.T) {
+	clock := p2p.NewManualClock(time.Now())
+	network := p2p.NewNetwork(p2p.NetworkOptions{Clock: clock, Seed: 1})
+	nodes := memoryNodes(network, clock, 2, p2p.Options{
+		Lookup:   []string{"mem://node-1"},
+		Detector: p2p.NewMissCountDetector(1, 2),
+		Interval: time.Second,
+	})
+
+	a, b := nodes[0], nodes[1]
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go a.Start(ctx)
+
+	if !eventually(t, time.Second, func() bool { return len(a.Peers()) == 1 }) {
+		t.Fatal("expected the first scan to run right away")
+	}
+
+	status := func() p2p.PeerStatus {
+		for _, peer := range a.Peers() {
+			return peer.Status
+		}
+		return -1
+	}
+
+	network.Crash(b.CurrentAddr())
+	time.Sleep(50 * time.Millisecond)
+	if status() != p2p.PeerStatus_ALIVE {
+		t.Fatalf("expected no scan to run before the clock moves, got %s", status())
+	}
+
+	dead := eventually(t, 2*time.Second, func() bool {
+		clock.Advance(time.Second)
+		return status() == p2p.PeerStatus_DEAD
+	})
+	if !dead {
+		t.Error("expected advancing the clock to drive the scans")
+	}
+}
@@ -0,0 +1,251 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	ProtocolHttp      = "http"
+	ProtocolGrpc      = "grpc"
+	ProtocolWebSocket = "websocket"
+	ProtocolTcp       = "tcp"
+)
+
+var (
+	ErrNoProtocol = errors.New("no common protocol")
+	ErrNoTLS      = errors.New("secure endpoint without a transport tls config")
+)
+
+// MultiTransport speaks several protocols, reaching each peer through the
+// endpoints it advertises in the order of Preference, and remembering the
+// one that worked so later messages go straight to it.
+type MultiTransport struct {
+	Transports map[string]Transport
+	Preference []string
+	byAddr     sync.Map
+	byPeer     sync.Map
+}
+
+type route struct {
+	protocol string
+	addr     string
+}
+
+// Connect reaches a peer only known by addr, which is either an endpoint or
+// a plain address, trying every protocol on the latter.
+func (m *MultiTransport) Connect(ctx context.Context, from *Peer, addr string) (*State, error) {
+	var routes []route
+	e, err := ParseEndpoint(addr)
+	if err == nil {
+		if !m.dialable(e.Protocol(), e.Scheme == "grpcs") {
+			return nil, fmt.Errorf("failed connecting to %s: %w", addr, ErrNoTLS)
+		}
+		routes = append(routes, route{e.Protocol(), e.dialAddr()})
+	} else {
+		known, ok := m.byAddr.Load(addr)
+		if ok {
+			routes = append(routes, known.(route))
+		}
+		for _, protocol := range m.Preference {
+			if m.dialable(protocol, strings.HasPrefix(addr, "https://")) {
+				routes = append(routes, route{protocol, protocolAddr(protocol, addr)})
+			}
+		}
+	}
+
+	state, r, err := m.connect(ctx, from, routes)
+	if err != nil {
+		return nil, fmt.Errorf("failed connecting to %s: %w", addr, err)
+	}
+
+	m.byAddr.Store(addr, r)
+	m.byPeer.Store(state.Current.GetId(), r)
+	return state, nil
+}
+
+// ConnectPeer reaches a known peer trying its endpoints in order.
+func (m *MultiTransport) ConnectPeer(ctx context.Context, from, to *Peer) (*State, error) {
+	routes := m.routes(to)
+	known, ok := m.byPeer.Load(to.Id)
+	if ok {
+		routes = append([]route{known.(route)}, routes...)
+	}
+
+	state, r, err := m.connect(ctx, from, routes)
+	if err != nil {
+		m.byPeer.Delete(to.Id)
+		return nil, fmt.Errorf("failed connecting to %s: %w", to.Addr, err)
+	}
+
+	m.byPeer.Store(to.Id, r)
+	return state, nil
+}
+
+func (m *MultiTransport) connect(ctx context.Context, from *Peer, routes []route) (*State, route, error) {
+	err := ErrNoProtocol
+	for _, r := range routes {
+		n, ok := m.Transports[r.protocol]
+		if !ok {
+			continue
+		}
+
+		var state *State
+		state, err = n.Connect(ctx, from, r.addr)
+		if err == nil {
+			return state, r, nil
+		}
+
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	return nil, route{}, err
+}
+
+func (m *MultiTransport) Send(ctx context.Context, from, to *Peer, subject string, body []byte) ([]byte, error) {
+	var reply []byte
+	err := m.try(ctx, to, func(n Transport, peer *Peer) error {
+		var err error
+		reply, err = n.Send(ctx, from, peer, subject, body)
+		return err
+	})
+	return reply, err
+}
+
+func (m *MultiTransport) Leave(ctx context.Context, from, to *Peer) error {
+	return m.try(ctx, to, func(n Transport, peer *Peer) error {
+		return n.Leave(ctx, from, peer)
+	})
+}
+
+// Forget forgets the route to the peer and lets every transport able to
+// reach it release what it holds for it.
+func (m *MultiTransport) Forget(to *Peer) {
+	m.byPeer.Delete(to.Id)
+	for _, r := range m.routes(to) {
+		f, ok := m.Transports[r.protocol].(PeerForgetter)
+		if !ok {
+			continue
+		}
+
+		peer := to
+		if r.addr != to.Addr {
+			peer = proto.Clone(to).(*Peer)
+			peer.Addr = r.addr
+		}
+		f.Forget(peer)
+	}
+}
+
+// try calls the peer through the route known to work first and then the
+// others, moving on only while the peer can't be reached, as an *Error is
+// the peer replying. The route that got through is remembered.
+func (m *MultiTransport) try(ctx context.Context, to *Peer, call func(Transport, *Peer) error) error {
+	routes := m.routes(to)
+	known, ok := m.byPeer.Load(to.Id)
+	if ok {
+		routes = append([]route{known.(route)}, routes...)
+	}
+
+	err := fmt.Errorf("%w with %s speaking %v", ErrNoProtocol, to.Addr, to.Protocols)
+	tried := make(map[route]bool)
+	for _, r := range routes {
+		n, ok := m.Transports[r.protocol]
+		if !ok || tried[r] {
+			continue
+		}
+		tried[r] = true
+
+		peer := to
+		if r.addr != to.Addr {
+			peer = proto.Clone(to).(*Peer)
+			peer.Addr = r.addr
+		}
+
+		err = call(n, peer)
+		var e *Error
+		if err == nil || errors.As(err, &e) {
+			m.byPeer.Store(to.Id, r)
+			return err
+		}
+
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	m.byPeer.Delete(to.Id)
+	return err
+}
+
+// routes lists where the peer can be reached, its endpoints ranked by
+// Preference, or its addr with the protocols it advertises for peers without
+// endpoints.
+func (m *MultiTransport) routes(to *Peer) []route {
+	var routes []route
+	for _, protocol := range m.Preference {
+		if len(to.Endpoints) > 0 {
+			for _, e := range to.Endpoints {
+				if e.Protocol() == protocol && m.dialable(protocol, e.Scheme == "grpcs") {
+					routes = append(routes, route{protocol, e.dialAddr()})
+				}
+			}
+			continue
+		}
+
+		if !m.dialable(protocol, strings.HasPrefix(to.Addr, "https://")) {
+			continue
+		}
+
+		if len(to.Protocols) == 0 || contains(to.Protocols, protocol) {
+			routes = append(routes, route{protocol, protocolAddr(protocol, to.Addr)})
+		}
+	}
+
+	if len(to.Endpoints) == 0 && len(to.Protocols) == 0 {
+		known, ok := m.byAddr.Load(to.Addr)
+		if ok {
+			routes = append([]route{known.(route)}, routes...)
+		}
+	}
+
+	return routes
+}
+
+// dialable reports whether the protocol transport can reach a secure address
+// over tls, grpc dials bare host:port addrs and would fall back to plaintext
+// without a TLSConfig.
+func (m *MultiTransport) dialable(protocol string, secure bool) bool {
+	if !secure || protocol != ProtocolGrpc {
+		return true
+	}
+
+	g, ok := m.Transports[protocol].(*GrpcTransport)
+	return !ok || g.TLSConfig != nil
+}
+
+// protocolAddr adapts addr to the protocol, grpc and tcp dial a bare
+// host:port while http and websocket expect an url.
+func protocolAddr(protocol, addr string) string {
+	if strings.HasPrefix(addr, unixScheme) {
+		return addr
+	}
+
+	switch protocol {
+	case ProtocolGrpc, ProtocolTcp:
+		addr = strings.TrimPrefix(addr, "http://")
+		return strings.TrimPrefix(addr, "https://")
+	case ProtocolHttp, ProtocolWebSocket:
+		if !strings.Contains(addr, "://") {
+			return "http://" + addr
+		}
+	}
+	return addr
+}
@@ -0,0 +1,343 @@
+package p2p
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+const maxFrameSize = 64 << 20
+
+// writeFrame writes f prefixed by its 4 bytes big endian length.
+func writeFrame(w io.Writer, f *Frame) error {
+	data, err := proto.Marshal(f)
+	if err != nil {
+		return fmt.Errorf("failed encoding frame: %w", err)
+	}
+
+	if len(data) > maxFrameSize {
+		return fmt.Errorf("frame of %d bytes exceeds the %d bytes limit", len(data), maxFrameSize)
+	}
+
+	buf := make([]byte, 4+len(data))
+	binary.BigEndian.PutUint32(buf, uint32(len(data)))
+	copy(buf[4:], data)
+
+	_, err = w.Write(buf)
+	return err
+}
+
+// maxHandshakeSize limits the frames read before the connection is verified,
+// so unauthenticated peers can't make the server allocate maxFrameSize.
+const maxHandshakeSize = 4 << 10
+
+func readFrame(r io.Reader, limit uint32) (*Frame, error) {
+	var size [4]byte
+	_, err := io.ReadFull(r, size[:])
+	if err != nil {
+		return nil, err
+	}
+
+	n := binary.BigEndian.Uint32(size[:])
+	if n > limit {
+		return nil, fmt.Errorf("frame of %d bytes exceeds the %d bytes limit", n, limit)
+	}
+
+	data := make([]byte, n)
+	_, err = io.ReadFull(r, data)
+	if err != nil {
+		return nil, err
+	}
+
+	var f Frame
+	err = proto.Unmarshal(data, &f)
+	if err != nil {
+		return nil, fmt.Errorf("failed decoding frame: %w", err)
+	}
+
+	return &f, nil
+}
+
+// TcpTransport sends length prefixed protobuf frames over a pool of up to
+// PoolSize tcp connections per peer address, multiplexing requests on each.
+type TcpTransport struct {
+	Key       string
+	TLSConfig *tls.Config
+	PoolSize  int
+	mutex     sync.Mutex
+	pools     map[string][]*tcpConn
+	next      int
+}
+
+type tcpConn struct {
+	conn    net.Conn
+	write   sync.Mutex
+	mutex   sync.Mutex
+	next    uint64
+	pending map[uint64]chan *Frame
+	joined  bool
+	state   *State
+	closed  chan struct{}
+}
+
+func (n *TcpTransport) Connect(ctx context.Context, from *Peer, addr string) (*State, error) {
+	c, err := n.conn(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mutex.Lock()
+	state := c.state
+	joined := c.joined
+	c.mutex.Unlock()
+
+	if joined && state != nil {
+		return proto.Clone(state).(*State), nil
+	}
+
+	reply, err := c.request(ctx, &Frame{Payload: &Frame_Connect{Connect: &ConnectRequest{Current: from}}})
+	if err != nil {
+		return nil, fmt.Errorf("failed at p2p connection: %w", err)
+	}
+
+	c.mutex.Lock()
+	c.joined = true
+	c.mutex.Unlock()
+
+	return reply.GetConnected().GetState(), nil
+}
+
+func (n *TcpTransport) Send(ctx context.Context, from, to *Peer, subject string, body []byte) ([]byte, error) {
+	c, err := n.conn(ctx, to.Addr)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &MessageRequest{From: from, Subject: subject, Body: body, ContentType: contentType(ctx), Headers: requestHeaders(ctx)}
+	reply, err := c.request(ctx, &Frame{Payload: &Frame_Message{Message: msg}})
+	if err != nil {
+		return nil, err
+	}
+
+	setReplyHeaders(ctx, reply.GetReply().GetHeaders())
+	return reply.GetReply().GetBody(), nil
+}
+
+func (n *TcpTransport) Leave(ctx context.Context, from, to *Peer) error {
+	c, err := n.conn(ctx, to.Addr)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.request(ctx, &Frame{Payload: &Frame_Leave{Leave: &LeaveRequest{Current: from}}})
+
+	n.mutex.Lock()
+	conns := n.pools[to.Addr]
+	delete(n.pools, to.Addr)
+	n.mutex.Unlock()
+
+	for _, c := range conns {
+		c.conn.Close()
+	}
+
+	return err
+}
+
+// Close closes every pooled connection.
+func (n *TcpTransport) Close() error {
+	n.mutex.Lock()
+	pools := n.pools
+	n.pools = nil
+	n.mutex.Unlock()
+
+	for _, conns := range pools {
+		for _, c := range conns {
+			c.conn.Close()
+		}
+	}
+	return nil
+}
+
+// conn picks a pooled connection to addr in turns, dialing a new one while
+// the pool isn't full.
+func (n *TcpTransport) conn(ctx context.Context, addr string) (*tcpConn, error) {
+	size := n.PoolSize
+	if size <= 0 {
+		size = 1
+	}
+
+	n.mutex.Lock()
+	conns := n.pools[addr]
+	if len(conns) >= size {
+		n.next++
+		c := conns[n.next%len(conns)]
+		n.mutex.Unlock()
+		return c, nil
+	}
+	n.mutex.Unlock()
+
+	conn, err := n.dial(ctx, addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed dialing %s: %w", addr, err)
+	}
+
+	c := &tcpConn{conn: conn, pending: make(map[uint64]chan *Frame), closed: make(chan struct{})}
+
+	n.mutex.Lock()
+	if conns := n.pools[addr]; len(conns) >= size {
+		n.mutex.Unlock()
+		conn.Close()
+		return conns[0], nil
+	}
+	if n.pools == nil {
+		n.pools = make(map[string][]*tcpConn)
+	}
+	n.pools[addr] = append(n.pools[addr], c)
+	n.mutex.Unlock()
+
+	go func() {
+		c.read()
+		n.drop(addr, c)
+	}()
+
+	return c, nil
+}
+
+func (n *TcpTransport) drop(addr string, c *tcpConn) {
+	n.mutex.Lock()
+	conns := n.pools[addr]
+	for i, pooled := range conns {
+		if pooled == c {
+			n.pools[addr] = append(conns[:i:i], conns[i+1:]...)
+			break
+		}
+	}
+	if len(n.pools[addr]) == 0 {
+		delete(n.pools, addr)
+	}
+	n.mutex.Unlock()
+	c.conn.Close()
+}
+
+// dial opens a connection to addr and authenticates it with a signed
+// handshake, which the server acknowledges before any other frame.
+func (n *TcpTransport) dial(ctx context.Context, addr string) (net.Conn, error) {
+	network, address := splitAddr(addr)
+
+	var conn net.Conn
+	var err error
+	if n.TLSConfig != nil {
+		d := tls.Dialer{Config: n.TLSConfig}
+		conn, err = d.DialContext(ctx, network, address)
+	} else {
+		var d net.Dialer
+		conn, err = d.DialContext(ctx, network, address)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	deadline, ok := ctx.Deadline()
+	if ok {
+		conn.SetDeadline(deadline)
+	}
+
+	timestamp := time.Now().Unix()
+	nonce := newNonce()
+	signature := sign(n.Key, "TCP", "/p2p", strconv.FormatInt(timestamp, 10), nonce, nil)
+	err = writeFrame(conn, &Frame{Payload: &Frame_Handshake{Handshake: &Handshake{Timestamp: timestamp, Nonce: nonce, Signature: signature}}})
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed sending handshake: %w", err)
+	}
+
+	ack, err := readFrame(conn, maxHandshakeSize)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed reading handshake: %w", err)
+	}
+
+	if ack.Error != "" {
+		conn.Close()
+		return nil, fmt.Errorf("handshake rejected: %s", ack.Error)
+	}
+
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+// request sends a frame and waits for the reply carrying its id.
+func (c *tcpConn) request(ctx context.Context, f *Frame) (*Frame, error) {
+	reply := make(chan *Frame, 1)
+
+	c.mutex.Lock()
+	c.next++
+	f.Id = c.next
+	c.pending[f.Id] = reply
+	c.mutex.Unlock()
+
+	defer func() {
+		c.mutex.Lock()
+		delete(c.pending, f.Id)
+		c.mutex.Unlock()
+	}()
+
+	deadline, ok := ctx.Deadline()
+	if ok {
+		f.TimeoutMs = time.Until(deadline).Milliseconds()
+	}
+
+	c.write.Lock()
+	err := writeFrame(c.conn, f)
+	c.write.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("failed sending frame: %w", err)
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.closed:
+		return nil, ErrConnectionClosed
+	case r := <-reply:
+		if r.Status != nil {
+			return nil, fmt.Errorf("reply error: %w", r.Status.err())
+		}
+		if r.Error != "" {
+			return nil, fmt.Errorf("reply error: %s", r.Error)
+		}
+		return r, nil
+	}
+}
+
+// read dispatches replies to their pending requests and caches pushed states
+// until the connection fails.
+func (c *tcpConn) read() {
+	defer close(c.closed)
+
+	r := bufio.NewReader(c.conn)
+	for {
+		f, err := readFrame(r, maxFrameSize)
+		if err != nil {
+			return
+		}
+
+		c.mutex.Lock()
+		state, pushed := f.Payload.(*Frame_State)
+		if pushed {
+			c.state = state.State.GetState()
+		} else if reply, ok := c.pending[f.Id]; ok {
+			reply <- f
+		}
+		c.mutex.Unlock()
+	}
+}
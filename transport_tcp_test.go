@@ -0,0 +1,63 @@
+package p2p_test
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yaien/p2p"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestTcpServer_Panic(t *testing.T) {
+	testServerPanic(t, tcpFactory())
+}
+
+func TestTcpServer_PushDisconnect(t *testing.T) {
+	testPushDisconnect(t, &p2p.TcpTransport{}, func(p *p2p.P2P, s *p2p.Subscriber) string {
+		return serveListener(t, p, p2p.NewTcpServer(p, s, ""))
+	})
+}
+
+func TestTcpServer_HandshakeLimit(t *testing.T) {
+	server := p2p.New(p2p.Options{Name: "server-p2p", Transport: &p2p.TcpTransport{}})
+	addr := serveListener(t, server, p2p.NewTcpServer(server, nil, ""))
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("failed dialing server: %s", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], 1<<20)
+	_, err = conn.Write(size[:])
+	if err != nil {
+		t.Fatalf("failed writing frame size: %s", err)
+	}
+
+	_, err = io.ReadFull(conn, size[:])
+	if err != nil {
+		t.Fatalf("expected the oversized handshake to be replied right away: %s", err)
+	}
+
+	data := make([]byte, binary.BigEndian.Uint32(size[:]))
+	_, err = io.ReadFull(conn, data)
+	if err != nil {
+		t.Fatalf("failed reading reply: %s", err)
+	}
+
+	var reply p2p.Frame
+	err = proto.Unmarshal(data, &reply)
+	if err != nil {
+		t.Fatalf("failed decoding reply: %s", err)
+	}
+
+	if !strings.Contains(reply.Error, "exceeds") {
+		t.Errorf("expected the handshake to be rejected for its size, got %q", reply.Error)
+	}
+}
@@ -0,0 +1,369 @@
+package p2p
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+	"google.golang.org/protobuf/proto"
+)
+
+var ErrConnectionClosed = errors.New("connection closed")
+
+const (
+	wsConnect = "connect"
+	wsMessage = "message"
+	wsLeave   = "leave"
+	wsReply   = "reply"
+	wsState   = "state"
+)
+
+// wsFrame is the envelope of everything sent over a websocket connection,
+// replies carry the id of the request they answer.
+type wsFrame struct {
+	Id          uint64            `json:"id,omitempty"`
+	Type        string            `json:"type"`
+	Peer        *Peer             `json:"peer,omitempty"`
+	Subject     string            `json:"subject,omitempty"`
+	Body        []byte            `json:"body,omitempty"`
+	ContentType string            `json:"content_type,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	State       *State            `json:"state,omitempty"`
+	Timeout     string            `json:"timeout,omitempty"`
+	Error       string            `json:"error,omitempty"`
+	Code        string            `json:"code,omitempty"`
+	Details     map[string]string `json:"details,omitempty"`
+}
+
+// WebSocketTransport keeps a single websocket connection per peer address,
+// multiplexing requests over it and caching the state the peer pushes, so
+// periodic connects don't need a round trip.
+type WebSocketTransport struct {
+	Key       string
+	TLSConfig *tls.Config
+	mutex     sync.Mutex
+	conns     map[string]*wsConn
+}
+
+type wsConn struct {
+	ws      *websocket.Conn
+	mutex   sync.Mutex
+	next    uint64
+	pending map[uint64]chan *wsFrame
+	joined  bool
+	state   *State
+	closed  chan struct{}
+}
+
+func (n *WebSocketTransport) Connect(ctx context.Context, from *Peer, addr string) (*State, error) {
+	c, err := n.conn(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mutex.Lock()
+	state := c.state
+	joined := c.joined
+	c.mutex.Unlock()
+
+	if joined && state != nil {
+		return proto.Clone(state).(*State), nil
+	}
+
+	reply, err := c.request(ctx, &wsFrame{Type: wsConnect, Peer: from})
+	if err != nil {
+		return nil, fmt.Errorf("failed at p2p connection: %w", err)
+	}
+
+	c.mutex.Lock()
+	c.joined = true
+	c.mutex.Unlock()
+
+	return reply.State, nil
+}
+
+func (n *WebSocketTransport) Send(ctx context.Context, from, to *Peer, subject string, body []byte) ([]byte, error) {
+	c, err := n.conn(ctx, to.Addr)
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := c.request(ctx, &wsFrame{Type: wsMessage, Peer: from, Subject: subject, Body: body, ContentType: contentType(ctx), Headers: requestHeaders(ctx)})
+	if err != nil {
+		return nil, err
+	}
+
+	setReplyHeaders(ctx, reply.Headers)
+	return reply.Body, nil
+}
+
+func (n *WebSocketTransport) Leave(ctx context.Context, from, to *Peer) error {
+	c, err := n.conn(ctx, to.Addr)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.request(ctx, &wsFrame{Type: wsLeave, Peer: from})
+	n.drop(to.Addr, c)
+	return err
+}
+
+// Close closes every open connection.
+func (n *WebSocketTransport) Close() error {
+	n.mutex.Lock()
+	conns := n.conns
+	n.conns = nil
+	n.mutex.Unlock()
+
+	for _, c := range conns {
+		c.ws.Close()
+	}
+	return nil
+}
+
+// conn returns the open connection to addr, dialing it when there's none.
+func (n *WebSocketTransport) conn(ctx context.Context, addr string) (*wsConn, error) {
+	n.mutex.Lock()
+	c, ok := n.conns[addr]
+	n.mutex.Unlock()
+	if ok {
+		return c, nil
+	}
+
+	ws, err := n.dial(ctx, addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed dialing %s: %w", addr, err)
+	}
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	c, ok = n.conns[addr]
+	if ok {
+		ws.Close()
+		return c, nil
+	}
+
+	c = &wsConn{ws: ws, pending: make(map[uint64]chan *wsFrame), closed: make(chan struct{})}
+	if n.conns == nil {
+		n.conns = make(map[string]*wsConn)
+	}
+	n.conns[addr] = c
+
+	go func() {
+		c.read()
+		n.drop(addr, c)
+	}()
+
+	return c, nil
+}
+
+func (n *WebSocketTransport) drop(addr string, c *wsConn) {
+	n.mutex.Lock()
+	if n.conns[addr] == c {
+		delete(n.conns, addr)
+	}
+	n.mutex.Unlock()
+	c.ws.Close()
+}
+
+// dial opens the websocket of the peer serving at addr, tunneling through
+// the environment's http proxy when there's one.
+func (n *WebSocketTransport) dial(ctx context.Context, addr string) (*websocket.Conn, error) {
+	network, address := splitAddr(addr)
+	if network == "unix" {
+		addr = "http://unix"
+	}
+
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed parsing addr: %w", err)
+	}
+
+	location := *u
+	location.Scheme = "ws"
+	if u.Scheme == "https" {
+		location.Scheme = "wss"
+	}
+	location.Path += "/p2p/ws"
+
+	config, err := websocket.NewConfig(location.String(), addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed creating config: %w", err)
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonce := newNonce()
+	config.Header.Set("X-Timestamp", timestamp)
+	config.Header.Set("X-Nonce", nonce)
+	config.Header.Set("X-Signature", sign(n.Key, "GET", location.Path, timestamp, nonce, nil))
+
+	if network == "tcp" {
+		address = u.Host
+		if u.Port() == "" {
+			port := "80"
+			if u.Scheme == "https" {
+				port = "443"
+			}
+			address = net.JoinHostPort(u.Hostname(), port)
+		}
+	}
+
+	var proxy *url.URL
+	if network == "tcp" {
+		proxy, err = http.ProxyFromEnvironment(&http.Request{URL: u})
+		if err != nil {
+			return nil, fmt.Errorf("failed resolving proxy: %w", err)
+		}
+	}
+
+	var d net.Dialer
+	var conn net.Conn
+	if proxy != nil {
+		conn, err = tunnel(ctx, proxy, address)
+	} else {
+		conn, err = d.DialContext(ctx, network, address)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Scheme == "https" {
+		config := n.TLSConfig.Clone()
+		if config == nil {
+			config = &tls.Config{}
+		}
+		if config.ServerName == "" {
+			config.ServerName = u.Hostname()
+		}
+		conn = tls.Client(conn, config)
+	}
+
+	deadline, ok := ctx.Deadline()
+	if ok {
+		conn.SetDeadline(deadline)
+	}
+
+	ws, err := websocket.NewClient(config, conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed at handshake: %w", err)
+	}
+
+	conn.SetDeadline(time.Time{})
+	return ws, nil
+}
+
+// tunnel opens a connection to host through an http proxy CONNECT.
+func tunnel(ctx context.Context, proxy *url.URL, host string) (net.Conn, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", proxy.Host)
+	if err != nil {
+		return nil, fmt.Errorf("failed dialing proxy: %w", err)
+	}
+
+	req := &http.Request{Method: "CONNECT", URL: &url.URL{Opaque: host}, Host: host, Header: make(http.Header)}
+	if proxy.User != nil {
+		password, _ := proxy.User.Password()
+		r := http.Request{Header: make(http.Header)}
+		r.SetBasicAuth(proxy.User.Username(), password)
+		req.Header.Set("Proxy-Authorization", r.Header.Get("Authorization"))
+	}
+
+	err = req.Write(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed writing proxy connect: %w", err)
+	}
+
+	res, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed reading proxy connect: %w", err)
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy connect failed with status %d", res.StatusCode)
+	}
+
+	return conn, nil
+}
+
+// request sends a frame and waits for the reply carrying its id.
+func (c *wsConn) request(ctx context.Context, frame *wsFrame) (*wsFrame, error) {
+	reply := make(chan *wsFrame, 1)
+
+	c.mutex.Lock()
+	c.next++
+	frame.Id = c.next
+	c.pending[frame.Id] = reply
+	c.mutex.Unlock()
+
+	defer func() {
+		c.mutex.Lock()
+		delete(c.pending, frame.Id)
+		c.mutex.Unlock()
+	}()
+
+	deadline, ok := ctx.Deadline()
+	if ok {
+		frame.Timeout = time.Until(deadline).String()
+	}
+
+	err := websocket.JSON.Send(c.ws, frame)
+	if err != nil {
+		return nil, fmt.Errorf("failed sending frame: %w", err)
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.closed:
+		return nil, ErrConnectionClosed
+	case r := <-reply:
+		if r.Code != "" {
+			return nil, fmt.Errorf("reply error: %w", &Error{Code: Code(r.Code), Message: r.Error, Details: r.Details})
+		}
+		if r.Error != "" {
+			return nil, fmt.Errorf("reply error: %s", r.Error)
+		}
+		return r, nil
+	}
+}
+
+// read dispatches replies to their pending requests and caches pushed states
+// until the connection fails.
+func (c *wsConn) read() {
+	defer close(c.closed)
+
+	for {
+		var frame wsFrame
+		err := websocket.JSON.Receive(c.ws, &frame)
+		if err != nil {
+			return
+		}
+
+		c.mutex.Lock()
+		switch frame.Type {
+		case wsState:
+			c.state = frame.State
+		case wsReply:
+			reply, ok := c.pending[frame.Id]
+			if ok {
+				reply <- &frame
+			}
+		}
+		c.mutex.Unlock()
+	}
+}
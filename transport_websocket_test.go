@@ -0,0 +1,207 @@
+package p2p_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yaien/p2p"
+	"github.com/yaien/p2p/p2ptest"
+	"golang.org/x/net/nettest"
+)
+
+func TestWebSocketTransport_Push(t *testing.T) {
+	mx := http.NewServeMux()
+	srv := httptest.NewServer(mx)
+	defer srv.Close()
+
+	to := p2p.New(p2p.Options{Addr: srv.URL, Name: "target-p2p", Transport: &p2p.WebSocketTransport{}})
+	p2p.NewWebSocketServer(to, p2p.NewSubscriber(to.Channel()), "").Register(mx)
+
+	from := p2p.New(p2p.Options{Name: "from-p2p", Transport: &p2p.WebSocketTransport{}})
+	err := from.Discover(to.CurrentAddr())
+	if err != nil {
+		t.Fatalf("failed at discover: %s", err)
+	}
+
+	other := p2p.New(p2p.Options{Name: "other-p2p", Transport: &p2p.WebSocketTransport{}})
+	err = other.Discover(to.CurrentAddr())
+	if err != nil {
+		t.Fatalf("failed at discover: %s", err)
+	}
+
+	ok := eventually(t, 2*time.Second, func() bool {
+		from.Discover(to.CurrentAddr())
+		return len(from.Peers()) == 2
+	})
+
+	if !ok {
+		t.Errorf("expected the pushed state to reveal the other peer, got %d peers", len(from.Peers()))
+	}
+}
+
+func TestWebSocketServer_PushDisconnect(t *testing.T) {
+	testPushDisconnect(t, &p2p.WebSocketTransport{}, func(p *p2p.P2P, s *p2p.Subscriber) string {
+		mx := http.NewServeMux()
+		srv := httptest.NewServer(mx)
+		t.Cleanup(srv.Close)
+		p2p.NewWebSocketServer(p, s, "").Register(mx)
+		p.SetCurrentAddr(srv.URL)
+		return srv.URL
+	})
+}
+
+// testPushDisconnect checks a peer that stops reading and then goes away,
+// while a state update is in flight to it, doesn't stall the delivery to the
+// other subscriptions.
+func testPushDisconnect(t *testing.T, client p2p.Transport, serve func(*p2p.P2P, *p2p.Subscriber) string) {
+	source := make(chan *p2p.State)
+	defer close(source)
+	subscriber := p2p.NewSubscriber(source)
+
+	server := p2p.New(p2p.Options{Name: "server-p2p", Transport: client})
+	addr := serve(server, subscriber)
+	host := strings.TrimPrefix(addr, "http://")
+	proxied, stall, cut := stallProxy(t, host)
+	server.SetCurrentAddr(strings.Replace(addr, host, proxied, 1))
+
+	from := p2p.New(p2p.Options{Name: "from-p2p", Transport: client})
+	err := from.Discover(server.CurrentAddr())
+	if err != nil {
+		t.Fatalf("failed at discover: %s", err)
+	}
+
+	updated, unsubscribe := subscriber.Subscribe()
+	defer unsubscribe()
+
+	state := server.State()
+	state.Current.Labels = map[string]string{"padding": strings.Repeat("x", 1<<20)}
+	deliver := func(i int) {
+		select {
+		case source <- state:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("expected the update %d to be taken by the subscriber", i)
+		}
+
+		select {
+		case <-updated:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("expected the update %d to be delivered", i)
+		}
+	}
+
+	stall()
+	for i := 0; i < 32; i++ {
+		deliver(i)
+	}
+
+	cut()
+	for i := 0; i < 4; i++ {
+		deliver(i)
+	}
+}
+
+// stallProxy forwards connections to addr, stall stops forwarding what the
+// server writes and cut closes every connection.
+func stallProxy(t *testing.T, addr string) (string, func(), func()) {
+	lis, err := nettest.NewLocalListener("tcp")
+	if err != nil {
+		t.Fatalf("failed creating proxy listener: %s", err)
+	}
+	t.Cleanup(func() { lis.Close() })
+
+	var mutex sync.Mutex
+	var conns []net.Conn
+	stalled := make(chan struct{})
+
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+
+			upstream, err := net.Dial("tcp", addr)
+			if err != nil {
+				conn.Close()
+				continue
+			}
+
+			mutex.Lock()
+			conns = append(conns, conn, upstream)
+			mutex.Unlock()
+
+			go io.Copy(upstream, conn)
+			go func() {
+				buf := make([]byte, 32<<10)
+				for {
+					select {
+					case <-stalled:
+						return
+					default:
+					}
+
+					n, err := upstream.Read(buf)
+					if err != nil {
+						return
+					}
+					conn.Write(buf[:n])
+				}
+			}()
+		}
+	}()
+
+	cut := func() {
+		mutex.Lock()
+		defer mutex.Unlock()
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}
+	t.Cleanup(cut)
+
+	return lis.Addr().String(), func() { close(stalled) }, cut
+}
+
+func TestWebSocketServer_Panic(t *testing.T) {
+	testServerPanic(t, webSocketFactory())
+}
+
+// testServerPanic checks a panicking handler is replied as an internal error
+// and leaves the connection serving the next frames.
+func testServerPanic(t *testing.T, f p2ptest.Factory) {
+	server := p2p.New(p2p.Options{Name: "server-p2p", Transport: f.NewTransport("")})
+	mx := p2p.NewServeMux()
+	mx.HandleFunc("panic", func(ctx context.Context, r *p2p.MessageRequest) ([]byte, error) {
+		panic("exploded")
+	})
+	mx.HandleFunc("echo", func(ctx context.Context, r *p2p.MessageRequest) ([]byte, error) {
+		return r.Body, nil
+	})
+	server.Handle(mx)
+	addr := f.Serve(t, server, "")
+
+	client := p2p.New(p2p.Options{Name: "client-p2p", Transport: f.NewTransport("")})
+	err := client.Discover(addr)
+	if err != nil {
+		t.Fatalf("failed at discover: %s", err)
+	}
+
+	_, err = client.Request("server-p2p", "panic", []byte(`{}`))
+	var e *p2p.Error
+	if !errors.As(err, &e) || e.Code != p2p.CodeInternal {
+		t.Fatalf("expected the panic to be replied as an internal error, got %v", err)
+	}
+
+	reply, err := client.Request("server-p2p", "echo", []byte(`{}`))
+	if err != nil || string(reply) != `{}` {
+		t.Errorf("expected the connection to keep serving, got %s %v", reply, err)
+	}
+}
@@ -0,0 +1,130 @@
+package p2p
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const unixScheme = "unix://"
+
+// splitAddr returns the network and address to dial addr, which is either a
+// tcp host:port or a unix:///path socket.
+func splitAddr(addr string) (network, address string) {
+	if strings.HasPrefix(addr, unixScheme) {
+		return "unix", strings.TrimPrefix(addr, unixScheme)
+	}
+	return "tcp", addr
+}
+
+// Listen listens on a tcp host:port or on a unix:///path socket, which is
+// created with the given file mode so only allowed users can connect to it.
+func Listen(addr string, mode os.FileMode) (net.Listener, error) {
+	network, address := splitAddr(addr)
+	if network == "tcp" {
+		return net.Listen(network, address)
+	}
+
+	info, err := os.Lstat(address)
+	if err == nil && info.Mode()&os.ModeSocket == 0 {
+		return nil, fmt.Errorf("%s already exists and is not a socket", address)
+	}
+	if err == nil {
+		conn, err := net.Dial("unix", address)
+		if err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("socket %s already in use", address)
+		}
+		os.Remove(address)
+	}
+
+	if mode == 0 {
+		return net.Listen("unix", address)
+	}
+
+	return listenUnix(address, mode)
+}
+
+// listenUnix creates the socket inside a private directory and only moves it
+// to path once it has the mode, so no one can connect to it before.
+func listenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".p2p")
+	if err != nil {
+		return nil, fmt.Errorf("failed creating socket dir: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	tmp := filepath.Join(dir, "s")
+	l, err := net.Listen("unix", tmp)
+	if err != nil {
+		return nil, err
+	}
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+
+	err = os.Chmod(tmp, mode)
+	if err != nil {
+		l.Close()
+		return nil, fmt.Errorf("failed setting socket mode: %w", err)
+	}
+
+	// the stale socket was removed by Listen, anything at path by now isn't
+	// ours to replace
+	_, err = os.Lstat(path)
+	if err == nil {
+		l.Close()
+		return nil, fmt.Errorf("%s already exists", path)
+	}
+
+	err = os.Rename(tmp, path)
+	if err != nil {
+		l.Close()
+		return nil, fmt.Errorf("failed moving socket: %w", err)
+	}
+
+	return &unixListener{Listener: l, addr: &net.UnixAddr{Name: path, Net: "unix"}}, nil
+}
+
+// unixListener reports and unlinks the path its socket was moved to.
+type unixListener struct {
+	net.Listener
+	addr *net.UnixAddr
+	once sync.Once
+}
+
+func (l *unixListener) Addr() net.Addr {
+	return l.addr
+}
+
+func (l *unixListener) Close() error {
+	err := l.Listener.Close()
+	l.once.Do(func() { os.Remove(l.addr.Name) })
+	return err
+}
+
+var unixClients sync.Map
+
+// httpTarget returns the client and base url to reach the http server at
+// addr, unix sockets get a client dialing the socket whatever the url host.
+func httpTarget(addr string) (*http.Client, string) {
+	network, path := splitAddr(addr)
+	if network == "tcp" {
+		return http.DefaultClient, addr
+	}
+
+	client, ok := unixClients.Load(path)
+	if !ok {
+		var d net.Dialer
+		client, _ = unixClients.LoadOrStore(path, &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return d.DialContext(ctx, "unix", path)
+			},
+		}})
+	}
+
+	return client.(*http.Client), "http://unix"
+}
@@ -0,0 +1,124 @@
+package p2p_test
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/yaien/p2p"
+	"github.com/yaien/p2p/p2ptest"
+)
+
+// socket returns a short unix socket addr, as socket paths are limited to
+// about a hundred bytes.
+func socket(tb testing.TB) (string, string) {
+	dir, err := os.MkdirTemp("", "p2p")
+	if err != nil {
+		tb.Fatalf("failed creating socket dir: %s", err)
+	}
+	tb.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "p2p.sock")
+	return "unix://" + path, path
+}
+
+func serveUnix(tb testing.TB, p *p2p.P2P, srv p2p.Server) string {
+	addr, _ := socket(tb)
+	lis, err := p2p.Listen(addr, 0600)
+	if err != nil {
+		tb.Fatalf("failed listening on %s: %s", addr, err)
+	}
+	go srv.Serve(lis)
+	tb.Cleanup(func() { srv.Close() })
+	p.SetCurrentAddr(addr)
+	return addr
+}
+
+func TestHttpTransport_Unix(t *testing.T) {
+	p2ptest.TestTransport(t, p2ptest.Factory{
+		NewTransport: func(key string) p2p.Transport {
+			return &p2p.HttpTransport{Key: key}
+		},
+		Serve: func(tb testing.TB, p *p2p.P2P, key string) string {
+			return serveUnix(tb, p, p2p.NewHttpServer(p, nil, key))
+		},
+	})
+}
+
+func TestGrpcTransport_Unix(t *testing.T) {
+	p2ptest.TestTransport(t, p2ptest.Factory{
+		NewTransport: func(key string) p2p.Transport {
+			return &p2p.GrpcTransport{Key: key}
+		},
+		Serve: func(tb testing.TB, p *p2p.P2P, key string) string {
+			return serveUnix(tb, p, p2p.NewGrpcServer(p, nil, key))
+		},
+	})
+}
+
+func TestListen_Unix(t *testing.T) {
+	addr, path := socket(t)
+
+	lis, err := p2p.Listen(addr, 0600)
+	if err != nil {
+		t.Fatalf("failed listening: %s", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("failed stating socket: %s", err)
+	}
+
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected socket mode 0600, got %o", info.Mode().Perm())
+	}
+
+	_, err = p2p.Listen(addr, 0600)
+	if err == nil {
+		t.Error("expected listening on a socket in use to fail")
+	}
+
+	if lis.Addr().String() != path {
+		t.Errorf("expected the listener on %s, got %s", path, lis.Addr())
+	}
+
+	lis.Close()
+	_, err = os.Stat(path)
+	if !os.IsNotExist(err) {
+		t.Errorf("expected closing to remove the socket, got %v", err)
+	}
+
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("failed creating stale socket: %s", err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	lis, err = p2p.Listen(addr, 0600)
+	if err != nil {
+		t.Fatalf("expected a stale socket to be replaced: %s", err)
+	}
+	lis.Close()
+}
+
+func TestListen_UnixExistingFile(t *testing.T) {
+	addr, path := socket(t)
+
+	err := os.WriteFile(path, []byte("data"), 0600)
+	if err != nil {
+		t.Fatalf("failed writing file: %s", err)
+	}
+
+	for _, mode := range []os.FileMode{0, 0600} {
+		_, err = p2p.Listen(addr, mode)
+		if err == nil {
+			t.Errorf("expected listening over a regular file with mode %o to fail", mode)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil || string(data) != "data" {
+			t.Errorf("expected the file to be left untouched, got %q, %v", data, err)
+		}
+	}
+}